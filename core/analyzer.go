@@ -1,7 +1,6 @@
 package core
 
 import (
-	"bufio"
 	"fmt"
 	"log"
 	"os"
@@ -25,59 +24,86 @@ type CodeLine struct {
 type ScanResult struct {
 	filenameMap map[string]CodeLine
 	mutex       sync.RWMutex
-	exempt      map[string]struct{}
+	exempt      map[string]string // exclude glob to where it came from
+	include     map[string]string // include glob to where it came from
+	root        string
+	ignoreFile  *gitignore.GitIgnore
+	resolver    FileResolver
+	archive     ArchiveLimits
+	binary      BinaryMode
+	truncated   map[string]string // partly scanned file to why
 }
 
-// DefaultExempt files that are skipped
+// FileSettings are the scan settings of a single file
+type FileSettings struct {
+	Filter      Filter
+	MaxFileSize int64
+}
+
+// FileResolver returns the settings of a file, or nil to keep the scan defaults
+type FileResolver func(path string) *FileSettings
+
+// scanJob is a file queued for scanning with the filter chosen for it
+type scanJob struct {
+	filename string
+	filter   Filter
+	limit    int64
+}
+
+// DefaultExempt globs of files that are skipped
 var DefaultExempt = []string{
 	"uv.lock", "pyproject.toml", "pnpm-lock.yaml", "package-lock.json",
 	"yarn.lock", "go.sum", "deno.lock", "Cargo.lock",
 	".gitignore", ".python-version", "LICENSE", ".gitaegis.jsonl",
+	".gitaegis.baseline.json", ".gitaegis.triage.json",
 	".git/", "gitaegis/",
 }
 
 // Init initializes ScanResult
 func (res *ScanResult) Init() {
 	res.filenameMap = make(map[string]CodeLine)
-	res.exempt = make(map[string]struct{}, len(DefaultExempt))
+	res.exempt = make(map[string]string, len(DefaultExempt))
+	res.include = make(map[string]string)
+	res.archive = DefaultArchiveLimits()
+	res.binary = BinarySkip
 	for _, f := range DefaultExempt {
-		res.exempt[f] = struct{}{}
+		res.exempt[f] = SourceDefault
 	}
 }
 
-// AddExempt adds a file to exemption list
+// AddExempt adds a glob to the exemption list
 func (res *ScanResult) AddExempt(file string) {
+	res.AddExemptFrom(file, SourceAdded)
+}
+
+// SetResolver installs per-file settings, consulted before each file is scanned
+func (res *ScanResult) SetResolver(r FileResolver) {
 	res.mutex.Lock()
 	defer res.mutex.Unlock()
-	res.exempt[file] = struct{}{}
+	res.resolver = r
 }
 
-// initGitIgnore loads .gitignore safely
-func initGitIgnore() *gitignore.GitIgnore {
-	ign, err := gitignore.CompileIgnoreFile(".gitignore")
-	if err != nil {
-		if os.IsNotExist(err) {
-			return gitignore.CompileIgnoreLines()
-		}
-		log.Printf("[core.analyzer] failed to load .gitignore (%v)", err)
-		return gitignore.CompileIgnoreLines()
+// settingsFor applies the resolver to a file, falling back to the scan defaults
+func (res *ScanResult) settingsFor(path string, filter Filter, maxFileSize int64) (Filter, int64) {
+	if res.resolver == nil {
+		return filter, maxFileSize
 	}
-	return ign
+	if s := res.resolver(path); s != nil {
+		return s.Filter, s.MaxFileSize
+	}
+	return filter, maxFileSize
 }
 
 // isExempt checks if a filename is in exemptions
 func (res *ScanResult) isExempt(filename string) bool {
-	base := filepath.Base(filename)
-	if _, ok := res.exempt[base]; ok {
-		return true
-	}
-	// Check for directory exemptions in the path
-	for exempt := range res.exempt {
-		if strings.Contains(filename, string(filepath.Separator)+exempt) || strings.HasPrefix(filename, exempt) {
-			return true
-		}
-	}
-	return false
+	return res.exemptReason(filename) != ""
+}
+
+// IsExempt reports whether a filename is in exemptions
+func (res *ScanResult) IsExempt(filename string) bool {
+	res.mutex.RLock()
+	defer res.mutex.RUnlock()
+	return res.isExempt(filename)
 }
 
 // isExecutable checks if file is executable
@@ -89,9 +115,13 @@ func isExecutable(filename string) bool {
 	return info.Mode().Perm()&0111 != 0
 }
 
-// ignoreFiles checks if a file is ignored by gitignore
-func ignoreFiles(path string, ign *gitignore.GitIgnore) bool {
-	return ign != nil && ign.MatchesPath(path)
+// ignoreFiles checks if a path is ignored by git
+func ignoreFiles(path string, isDir bool, ign *GitIgnorer) bool {
+	if ign == nil {
+		return false
+	}
+	ignored, _ := ign.Match(path, isDir)
+	return ignored
 }
 
 // IsFilenameMapEmpty returns true if no files have been scanned
@@ -106,43 +136,60 @@ func (res *ScanResult) ClearMap() {
 	res.mutex.Lock()
 	defer res.mutex.Unlock()
 	res.filenameMap = make(map[string]CodeLine)
+	res.truncated = nil
 }
 
-// IterFolder scans a folder recursively
-func (res *ScanResult) IterFolder(root string, filter LineFilter, useGitIgnore bool, maxFileSize int64) error {
-	var ign *gitignore.GitIgnore
+// IterFolder scans a folder recursively. With useGitIgnore, paths git ignores
+// are skipped, with patterns relative to the repository containing root.
+func (res *ScanResult) IterFolder(root string, filter Filter, useGitIgnore bool, maxFileSize int64) error {
+	var ign *GitIgnorer
 	if useGitIgnore {
-		ign = initGitIgnore()
+		ign = NewGitIgnorer(root)
 	}
 
-	files := make([]string, 0, 512)
+	files := make([]scanJob, 0, 512)
 	err := filepath.WalkDir(root, func(p string, d os.DirEntry, err error) error {
-		if err != nil || d.IsDir() {
+		if err != nil {
 			return err
 		}
-		if ignoreFiles(p, ign) || res.isExempt(p) || isExecutable(p) {
+		if d.IsDir() {
+			if p != root && ignoreFiles(p, true, ign) {
+				return filepath.SkipDir
+			}
 			return nil
 		}
+		if ignoreFiles(p, false, ign) || res.isExempt(p) || isExecutable(p) {
+			return nil
+		}
+		fileFilter, limit := res.settingsFor(p, filter, maxFileSize)
 		info, err := d.Info()
-		if err != nil || info.Size() > maxFileSize {
+		// Archives are bounded by the archive limits instead of their size
+		if err != nil || (info.Size() > limit && !res.scansArchive(p)) {
 			return nil
 		}
-		files = append(files, p)
+		files = append(files, scanJob{p, fileFilter, limit})
 		return nil
 	})
 	if err != nil {
 		return err
 	}
+	res.scanJobs(files)
+	return nil
+}
+
+// scanJobs scans queued files concurrently and records their findings
+func (res *ScanResult) scanJobs(files []scanJob) {
 
 	numWorkers := runtime.NumCPU()
-	fileCh := make(chan string, numWorkers*2)
+	fileCh := make(chan scanJob, numWorkers*2)
 	var wg sync.WaitGroup
 
 	for i := 0; i < numWorkers; i++ {
 		wg.Add(1)
 		go func() {
 			defer wg.Done()
-			for filename := range fileCh {
+			for job := range fileCh {
+				filename := job.filename
 				var lines *CodeLine
 				defer func() {
 					if r := recover(); r != nil {
@@ -150,18 +197,43 @@ func (res *ScanResult) IterFolder(root string, filter LineFilter, useGitIgnore b
 					}
 				}()
 
-				tree, code, err := CreateTree(filename)
+				if res.scansArchive(filename) {
+					if err := res.ScanArchive(filename, job.filter, job.limit); err != nil {
+						res.markTruncated(filename, err)
+					}
+					continue
+				}
+
+				if IsNotebook(filename) {
+					found, err := res.ScanNotebook(filename, job.filter)
+					if err == nil {
+						for path, cell := range found {
+							res.record(path, &cell)
+						}
+						continue
+					}
+					log.Printf("[core.notebook] falling back to a line scan of %s: %v", filename, err)
+				}
+
+				if StructuredFormat(filename) != "" {
+					var err error
+					if lines, err = res.ScanStructured(filename, job.filter); err == nil {
+						res.record(filename, lines)
+						continue
+					}
+					log.Printf("[core.structured] falling back to a line scan of %s: %v", filename, err)
+				}
+
+				tree, code, enc, bom, err := CreateTree(filename)
 				if err != nil {
-					lines = res.PerLineScan(filename, filter)
+					lines = res.PerLineScan(filename, job.filter)
 				} else {
-					lines = walkParse(tree.RootNode(), filter, code)
+					lines = walkParse(tree.RootNode(), inFile(job.filter, filename, LanguageOf(filename)), code)
+					remapColumns(lines, code, enc, bom)
+					markEncoding(lines, enc)
 				}
 
-				if lines != nil && len(lines.Lines) > 0 {
-					res.mutex.Lock()
-					res.filenameMap[filename] = *lines
-					res.mutex.Unlock()
-				}
+				res.record(filename, lines)
 			}
 		}()
 	}
@@ -171,57 +243,57 @@ func (res *ScanResult) IterFolder(root string, filter LineFilter, useGitIgnore b
 	}
 	close(fileCh)
 	wg.Wait()
-	return nil
 }
 
+// record stores the findings of a file, if any
+func (res *ScanResult) record(filename string, lines *CodeLine) {
+	if lines == nil || len(lines.Lines) == 0 {
+		return
+	}
+	res.mutex.Lock()
+	defer res.mutex.Unlock()
+	res.filenameMap[filename] = *lines
+}
 
-func (res *ScanResult) IterFiles(files []string, filter LineFilter, maxFileSize int64) error {
-	numWorkers := runtime.NumCPU()
-	fileCh := make(chan string, numWorkers*2)
-	var wg sync.WaitGroup
-
-	for i := 0; i < numWorkers; i++ {
-		wg.Add(1)
-		go func() {
-			defer wg.Done()
-			for filename := range fileCh {
-				var lines *CodeLine
-				defer func() {
-					if r := recover(); r != nil {
-						log.Printf("[core.analyzer] Recovered panic scanning %s: %v", filename, r)
-					}
-				}()
-
-				tree, code, err := CreateTree(filename)
-				if err != nil {
-					lines = res.PerLineScan(filename, filter)
-				} else {
-					lines = walkParse(tree.RootNode(), filter, code)
-				}
-
-				if lines != nil && len(lines.Lines) > 0 {
-					res.mutex.Lock()
-					res.filenameMap[filename] = *lines
-					res.mutex.Unlock()
-				}
-			}
-		}()
+// keep returns the findings of c for which keep returns true
+func (c CodeLine) keep(keep func(i int) bool) CodeLine {
+	var kept CodeLine
+	for i, text := range c.Lines {
+		if !keep(i) {
+			continue
+		}
+		kept.Lines = append(kept.Lines, text)
+		if i < len(c.Indexes) {
+			kept.Indexes = append(kept.Indexes, c.Indexes[i])
+		}
+		if i < len(c.Columns) {
+			kept.Columns = append(kept.Columns, c.Columns[i])
+		}
+		if i < len(c.Extracted) {
+			kept.Extracted = append(kept.Extracted, c.Extracted[i])
+		}
 	}
+	return kept
+}
 
+// IterFiles scans the given files, skipping directories and files over the size limit
+func (res *ScanResult) IterFiles(files []string, filter Filter, maxFileSize int64) error {
+	jobs := make([]scanJob, 0, len(files))
 	for _, f := range files {
+		fileFilter, limit := res.settingsFor(f, filter, maxFileSize)
 		info, err := os.Stat(f)
-		if err != nil || info.IsDir() || info.Size() > maxFileSize {
+		if err != nil || info.IsDir() || (info.Size() > limit && !res.scansArchive(f)) {
 			continue
 		}
-		fileCh <- f
+		jobs = append(jobs, scanJob{f, fileFilter, limit})
 	}
-	close(fileCh)
-	wg.Wait()
+	res.scanJobs(jobs)
 	return nil
 }
+
 // PerLineScan scans file line by line as a fallback
-func (res *ScanResult) PerLineScan(filename string, filter LineFilter) *CodeLine {
-	if filter == nil {
+func (res *ScanResult) PerLineScan(filename string, filter Filter) *CodeLine {
+	if isNilFilter(filter) {
 		return nil
 	}
 
@@ -231,37 +303,11 @@ func (res *ScanResult) PerLineScan(filename string, filter LineFilter) *CodeLine
 		return nil
 	}
 	defer f.Close()
-
-	scanner := bufio.NewScanner(f)
-	var lines []string
-	var indexes, columns []int
-	var extracted []Payload
-	lineNum := 1
-
-	for scanner.Scan() {
-		parts := strings.Fields(scanner.Text())
-		for col, token := range parts {
-			pl, ok := filter(token)
-			if ok && pl != nil {
-				lines = append(lines, token)
-				indexes = append(indexes, lineNum)
-				columns = append(columns, col+1)
-				extracted = append(extracted, pl)
-			}
-		}
-		lineNum++
-	}
-
-	if len(lines) == 0 {
-		return nil
-	}
-
-	return &CodeLine{
-		Lines:     lines,
-		Indexes:   indexes,
-		Columns:   columns,
-		Extracted: extracted,
+	lines, err := res.scanReader(f, inFile(filter, filename, LanguageOf(filename)))
+	if err != nil {
+		res.markTruncated(filename, err)
 	}
+	return lines
 }
 
 // PrettyPrintResults prints results with colors
@@ -0,0 +1,316 @@
+package core
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"compress/bzip2"
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path"
+	"strings"
+)
+
+// ArchiveEntrySep separates an archive from the entry inside it in virtual
+// paths, e.g. lib.jar!/config/app.properties
+const ArchiveEntrySep = "!/"
+
+// ArchiveLimits bound how much of an archive is unpacked while scanning
+type ArchiveLimits struct {
+	MaxDepth      int   // nesting depth of archives in archives, 0 disables archive scanning
+	MaxTotalBytes int64 // uncompressed bytes read per top-level archive
+	MaxRatio      int64 // compression ratio above which an entry is treated as a zip bomb
+	MaxEntries    int   // entries visited per top-level archive
+}
+
+// DefaultArchiveLimits returns the limits used unless configured otherwise
+func DefaultArchiveLimits() ArchiveLimits {
+	return ArchiveLimits{
+		MaxDepth:      3,
+		MaxTotalBytes: 256 << 20,
+		MaxRatio:      100,
+		MaxEntries:    10000,
+	}
+}
+
+var (
+	errArchiveBudget = errors.New("archive exceeds the maximum total size")
+	errArchiveBomb   = errors.New("compression ratio exceeds the limit, possible zip bomb")
+	errArchiveCount  = errors.New("archive exceeds the maximum number of entries")
+)
+
+type archiveKind int
+
+const (
+	notArchive archiveKind = iota
+	zipArchive
+	tarArchive
+	tarGzArchive
+	tarBz2Archive
+	gzFile
+)
+
+// archiveKindOf detects the archive format from a file name
+func archiveKindOf(name string) archiveKind {
+	lower := strings.ToLower(name)
+	switch {
+	case strings.HasSuffix(lower, ".tar.gz"), strings.HasSuffix(lower, ".tgz"):
+		return tarGzArchive
+	case strings.HasSuffix(lower, ".tar.bz2"), strings.HasSuffix(lower, ".tbz2"):
+		return tarBz2Archive
+	case strings.HasSuffix(lower, ".gz"):
+		return gzFile
+	}
+	switch path.Ext(lower) {
+	case ".zip", ".jar", ".war", ".ear", ".whl", ".egg", ".apk", ".aar", ".nupkg", ".xpi":
+		return zipArchive
+	case ".tar":
+		return tarArchive
+	}
+	return notArchive
+}
+
+// IsArchive reports whether a file name has a supported archive extension
+func IsArchive(name string) bool {
+	return archiveKindOf(name) != notArchive
+}
+
+// IsArchiveEntry reports whether path is a virtual path inside an archive
+func IsArchiveEntry(path string) bool {
+	return strings.Contains(path, ArchiveEntrySep)
+}
+
+// ArchiveFile returns the file on disk holding a virtual path
+func ArchiveFile(path string) string {
+	if i := strings.Index(path, ArchiveEntrySep); i >= 0 {
+		return path[:i]
+	}
+	return path
+}
+
+// SetArchiveLimits configures archive scanning, MaxDepth 0 disables it
+func (res *ScanResult) SetArchiveLimits(limits ArchiveLimits) {
+	res.mutex.Lock()
+	defer res.mutex.Unlock()
+	res.archive = limits
+}
+
+// scansArchive reports whether a file is unpacked instead of scanned as is
+func (res *ScanResult) scansArchive(filename string) bool {
+	return res.archive.MaxDepth > 0 && IsArchive(filename)
+}
+
+// archiveWalk holds the budget shared by all entries of one top-level archive
+type archiveWalk struct {
+	res        *ScanResult
+	filter     Filter
+	limits     ArchiveLimits
+	entryLimit int64
+	read       int64
+	entries    int
+	found      map[string]CodeLine
+}
+
+// budgetReader charges every byte read to the archive budget
+type budgetReader struct {
+	r io.Reader
+	w *archiveWalk
+}
+
+func (b *budgetReader) Read(p []byte) (int, error) {
+	n, err := b.r.Read(p)
+	b.w.read += int64(n)
+	if b.w.read > b.w.limits.MaxTotalBytes {
+		return n, errArchiveBudget
+	}
+	return n, err
+}
+
+// countingReader counts the compressed bytes consumed by a decompressor
+type countingReader struct {
+	r io.Reader
+	n int64
+}
+
+func (c *countingReader) Read(p []byte) (int, error) {
+	n, err := c.r.Read(p)
+	c.n += int64(n)
+	return n, err
+}
+
+// ratioReader fails once the decompressed output outgrows its input by more
+// than the allowed ratio. The first megabyte is always allowed.
+type ratioReader struct {
+	r   io.Reader
+	in  *countingReader
+	out int64
+	max int64
+}
+
+func (r *ratioReader) Read(p []byte) (int, error) {
+	n, err := r.r.Read(p)
+	r.out += int64(n)
+	if r.out > 1<<20 && r.out > r.in.n*r.max {
+		return n, errArchiveBomb
+	}
+	return n, err
+}
+
+// ScanArchive streams every entry of an archive through filter, recording
+// findings under virtual paths like lib.jar!/config/app.properties. Entries
+// larger than entryLimit are skipped. Scanning stops at the first limit hit,
+// keeping the findings made so far.
+func (res *ScanResult) ScanArchive(filename string, filter Filter, entryLimit int64) error {
+	if isNilFilter(filter) {
+		return nil
+	}
+	f, err := os.Open(filename)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	info, err := f.Stat()
+	if err != nil {
+		return err
+	}
+
+	w := &archiveWalk{
+		res:        res,
+		filter:     filter,
+		limits:     res.archive,
+		entryLimit: entryLimit,
+		found:      make(map[string]CodeLine),
+	}
+	err = w.walk(filename, f, info.Size(), 1)
+
+	res.mutex.Lock()
+	for path, lines := range w.found {
+		res.filenameMap[path] = lines
+	}
+	res.mutex.Unlock()
+	return err
+}
+
+// walk visits the entries of the archive name read from r
+func (w *archiveWalk) walk(name string, r io.Reader, size int64, depth int) error {
+	switch archiveKindOf(name) {
+	case zipArchive:
+		ra, ok := r.(io.ReaderAt)
+		if !ok {
+			data, err := io.ReadAll(&budgetReader{r, w})
+			if err != nil {
+				return err
+			}
+			ra, size = bytes.NewReader(data), int64(len(data))
+		}
+		zr, err := zip.NewReader(ra, size)
+		if err != nil {
+			return fmt.Errorf("%s: %w", name, err)
+		}
+		for _, f := range zr.File {
+			if f.FileInfo().IsDir() {
+				continue
+			}
+			if f.CompressedSize64 > 0 && f.UncompressedSize64/f.CompressedSize64 > uint64(w.limits.MaxRatio) {
+				return fmt.Errorf("%s%s%s: %w", name, ArchiveEntrySep, f.Name, errArchiveBomb)
+			}
+			rc, err := f.Open()
+			if err != nil {
+				return fmt.Errorf("%s%s%s: %w", name, ArchiveEntrySep, f.Name, err)
+			}
+			err = w.entry(name+ArchiveEntrySep+f.Name, rc, int64(f.UncompressedSize64), depth)
+			rc.Close()
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+
+	case tarArchive:
+		return w.walkTar(name, r, depth)
+
+	case tarGzArchive, gzFile:
+		in := &countingReader{r: r}
+		gz, err := gzip.NewReader(in)
+		if err != nil {
+			return fmt.Errorf("%s: %w", name, err)
+		}
+		defer gz.Close()
+		out := &ratioReader{r: gz, in: in, max: w.limits.MaxRatio}
+		if archiveKindOf(name) == tarGzArchive {
+			return w.walkTar(name, out, depth)
+		}
+		inner := strings.TrimSuffix(path.Base(name), path.Ext(name))
+		return w.entry(name+ArchiveEntrySep+inner, out, -1, depth)
+
+	case tarBz2Archive:
+		in := &countingReader{r: r}
+		out := &ratioReader{r: bzip2.NewReader(in), in: in, max: w.limits.MaxRatio}
+		return w.walkTar(name, out, depth)
+	}
+	return nil
+}
+
+// walkTar visits the regular files of a tar stream
+func (w *archiveWalk) walkTar(name string, r io.Reader, depth int) error {
+	tr := tar.NewReader(r)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("%s: %w", name, err)
+		}
+		if hdr.Typeflag != tar.TypeReg {
+			continue
+		}
+		if err := w.entry(name+ArchiveEntrySep+hdr.Name, tr, hdr.Size, depth); err != nil {
+			return err
+		}
+	}
+}
+
+// entry scans one archive member, descending into nested archives
+func (w *archiveWalk) entry(vpath string, r io.Reader, size int64, depth int) error {
+	w.entries++
+	if w.entries > w.limits.MaxEntries {
+		return errArchiveCount
+	}
+	if w.res.isExempt(vpath) {
+		return nil
+	}
+	if IsArchive(vpath) {
+		if depth >= w.limits.MaxDepth {
+			log.Printf("[core.archive] skipping %s: nested deeper than %d", vpath, w.limits.MaxDepth)
+			return nil
+		}
+		return w.walk(vpath, r, size, depth+1)
+	}
+	if size > w.entryLimit {
+		return nil
+	}
+
+	// Entries of unknown size are cut at the entry limit
+	lines, err := w.res.scanReader(&budgetReader{&limitReader{r: r, n: w.entryLimit}, w}, inFile(w.filter, vpath, LanguageOf(vpath)))
+	if lines != nil {
+		w.found[vpath] = *lines
+	}
+	if w.read > w.limits.MaxTotalBytes {
+		return errArchiveBudget
+	}
+	if errors.Is(err, errTruncated) {
+		w.res.markTruncated(vpath, err)
+	} else if err != nil {
+		return fmt.Errorf("%s: %w", vpath, err)
+	}
+	// Drain the rest so a ratio or format error surfaces before the next entry
+	if _, err := io.Copy(io.Discard, &budgetReader{r, w}); err != nil && !errors.Is(err, errArchiveBudget) {
+		return fmt.Errorf("%s: %w", vpath, err)
+	}
+	return nil
+}
@@ -0,0 +1,99 @@
+package core
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+const archiveSecret = "k8Vq2Lr9Zx4Pw7Mn3Tb6Yc1Hd5Gf0Js"
+
+// zipBytes builds a zip archive holding the given files
+func zipBytes(t *testing.T, files map[string][]byte) []byte {
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	for name, data := range files {
+		w, err := zw.Create(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		w.Write(data)
+	}
+	zw.Close()
+	return buf.Bytes()
+}
+
+func TestScanArchive_Nested(t *testing.T) {
+	dir := t.TempDir()
+	inner := zipBytes(t, map[string][]byte{"config/app.properties": []byte("key=" + archiveSecret + "\n")})
+
+	var tgz bytes.Buffer
+	gz := gzip.NewWriter(&tgz)
+	tw := tar.NewWriter(gz)
+	tw.WriteHeader(&tar.Header{Name: "lib/inner.jar", Mode: 0644, Size: int64(len(inner)), Typeflag: tar.TypeReg})
+	tw.Write(inner)
+	tw.Close()
+	gz.Close()
+
+	outer := filepath.Join(dir, "bundle.tar.gz")
+	os.WriteFile(outer, tgz.Bytes(), 0644)
+
+	result := &ScanResult{}
+	result.Init()
+	if err := result.ScanArchive(outer, EntropyFilter(3.0), 1<<20); err != nil {
+		t.Fatalf("ScanArchive failed: %v", err)
+	}
+	want := outer + "!/lib/inner.jar!/config/app.properties"
+	lines, ok := result.filenameMap[want]
+	if !ok {
+		t.Fatalf("expected finding under %s, got %v", want, result.filenameMap)
+	}
+	if lines.Indexes[0] != 1 {
+		t.Errorf("unexpected line %d", lines.Indexes[0])
+	}
+	if ArchiveFile(want) != outer {
+		t.Errorf("ArchiveFile(%q) = %q", want, ArchiveFile(want))
+	}
+
+	// Nested archives beyond the depth limit are not opened
+	result = &ScanResult{}
+	result.Init()
+	result.SetArchiveLimits(ArchiveLimits{MaxDepth: 1, MaxTotalBytes: 1 << 20, MaxRatio: 100, MaxEntries: 10})
+	result.ScanArchive(outer, EntropyFilter(3.0), 1<<20)
+	if !result.IsFilenameMapEmpty() {
+		t.Error("depth limit should stop at the outer archive")
+	}
+}
+
+func TestScanArchive_Bomb(t *testing.T) {
+	dir := t.TempDir()
+	bomb := filepath.Join(dir, "bomb.zip")
+	os.WriteFile(bomb, zipBytes(t, map[string][]byte{"zeros.txt": bytes.Repeat([]byte{'0'}, 10<<20)}), 0644)
+
+	result := &ScanResult{}
+	result.Init()
+	err := result.ScanArchive(bomb, EntropyFilter(3.0), 1<<30)
+	if err == nil || !strings.Contains(err.Error(), "zip bomb") {
+		t.Errorf("expected zip bomb error, got %v", err)
+	}
+
+	gzBomb := filepath.Join(dir, "zeros.txt.gz")
+	var buf bytes.Buffer
+	gz := gzip.NewWriter(&buf)
+	gz.Write(bytes.Repeat([]byte{'0'}, 10<<20))
+	gz.Close()
+	os.WriteFile(gzBomb, buf.Bytes(), 0644)
+	if err := result.ScanArchive(gzBomb, EntropyFilter(3.0), 1<<30); err == nil {
+		t.Error("expected gzip ratio error")
+	}
+
+	result.SetArchiveLimits(ArchiveLimits{MaxDepth: 3, MaxTotalBytes: 1024, MaxRatio: 1 << 20, MaxEntries: 10})
+	if err := result.ScanArchive(bomb, EntropyFilter(3.0), 1<<30); err != errArchiveBudget {
+		t.Errorf("expected total size error, got %v", err)
+	}
+}
@@ -0,0 +1,216 @@
+package core
+
+import (
+	"bufio"
+	"bytes"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// BinaryMode selects how files with binary content are handled
+type BinaryMode string
+
+const (
+	// BinarySkip leaves binary files unscanned
+	BinarySkip BinaryMode = "skip"
+	// BinaryStrings scans the printable runs of binary files, like strings(1)
+	BinaryStrings BinaryMode = "strings"
+)
+
+// sniffLen is how much of a file is inspected to decide if it is binary
+const sniffLen = 8192
+
+// minStringRun is the shortest printable run extracted in strings mode
+const minStringRun = 4
+
+// magicNumbers identify common binary formats by their first bytes
+var magicNumbers = []struct {
+	magic []byte
+	kind  string
+}{
+	{[]byte("\x89PNG\r\n\x1a\n"), "png image"},
+	{[]byte("\xff\xd8\xff"), "jpeg image"},
+	{[]byte("GIF8"), "gif image"},
+	{[]byte("SQLite format 3\x00"), "sqlite database"},
+	{[]byte("\x7fELF"), "elf executable"},
+	{[]byte("\xcf\xfa\xed\xfe"), "mach-o executable"},
+	{[]byte("\xca\xfe\xba\xbe"), "java class or mach-o binary"},
+	{[]byte("\x00asm"), "webassembly module"},
+	{[]byte("wOFF"), "woff font"},
+	{[]byte("wOF2"), "woff2 font"},
+	{[]byte("OTTO"), "opentype font"},
+	{[]byte("\x00\x01\x00\x00"), "truetype font"},
+	{[]byte("%PDF-"), "pdf document"},
+	{[]byte("PK\x03\x04"), "zip archive"},
+	{[]byte("\x1f\x8b"), "gzip data"},
+	{[]byte("BZh"), "bzip2 data"},
+	{[]byte("\xfd7zXZ\x00"), "xz data"},
+	{[]byte("7z\xbc\xaf\x27\x1c"), "7z archive"},
+	{[]byte("RIFF"), "riff media"},
+	{[]byte("OggS"), "ogg media"},
+	{[]byte("ID3"), "mp3 audio"},
+}
+
+// textContentTypes are the non text/* types http.DetectContentType gives text
+var textContentTypes = []string{"application/json", "application/xml", "application/javascript"}
+
+// magicKind names the binary format head starts with, if any. Magic numbers
+// that are plain text, like OTTO or RIFF, also need a control byte in head so
+// text starting with the same letters is not taken for binary.
+func magicKind(head []byte) string {
+	for _, m := range magicNumbers {
+		if !bytes.HasPrefix(head, m.magic) {
+			continue
+		}
+		if isText(m.magic) && isText(head) {
+			continue
+		}
+		return m.kind
+	}
+	return ""
+}
+
+// isText reports whether b has none of the control bytes
+// http.DetectContentType takes for binary
+func isText(b []byte) bool {
+	for _, c := range b {
+		if c <= 0x08 || c == 0x0b || (c >= 0x0e && c <= 0x1a) || (c >= 0x1c && c <= 0x1f) {
+			return false
+		}
+	}
+	return true
+}
+
+// SniffBinary reports whether the head of a file is binary, and what it looks like
+func SniffBinary(head []byte) (bool, string) {
+	if len(head) > sniffLen {
+		head = head[:sniffLen]
+	}
+	if len(head) == 0 {
+		return false, ""
+	}
+	if kind := magicKind(head); kind != "" {
+		return true, kind
+	}
+	if bytes.IndexByte(head, 0) >= 0 {
+		return true, "contains NUL bytes"
+	}
+	// content sniffing also knows text signatures such as OTTO and ID3
+	if isText(head) {
+		return false, ""
+	}
+	ct := http.DetectContentType(head)
+	if strings.HasPrefix(ct, "text/") {
+		return false, ""
+	}
+	for _, t := range textContentTypes {
+		if strings.HasPrefix(ct, t) {
+			return false, ""
+		}
+	}
+	return true, ct
+}
+
+// isPrintable reports whether b belongs in a strings(1) run
+func isPrintable(b byte) bool {
+	return b == '\t' || (b >= 0x20 && b < 0x7f)
+}
+
+// scanStrings runs filter over the tokens of every printable run of at least
+// minStringRun bytes. Lines count newlines in the raw content, columns are
+// byte columns in that line and each finding records its byte offset.
+func scanStrings(r io.Reader, filter Filter) (*CodeLine, error) {
+	br := bufio.NewReader(r)
+	var result CodeLine
+	var run []byte
+	line, offset, start, lineStart := 1, int64(0), int64(0), int64(0)
+
+	flush := func() {
+		if len(run) >= minStringRun {
+			text := string(run)
+			for i := 0; i < len(text); {
+				for i < len(text) && (text[i] == ' ' || text[i] == '\t') {
+					i++
+				}
+				j := i
+				for j < len(text) && text[j] != ' ' && text[j] != '\t' {
+					j++
+				}
+				if j > i {
+					token := text[i:j]
+					col := int(start-lineStart) + i + 1
+					if pl, ok := filter.Check(Candidate{Text: token, Line: line, Column: col, Source: text}); ok && pl != nil {
+						merged := Payload{"offset": strconv.FormatInt(start+int64(i), 10)}
+						for k, v := range pl {
+							merged[k] = v
+						}
+						result.Lines = append(result.Lines, token)
+						result.Indexes = append(result.Indexes, line)
+						result.Columns = append(result.Columns, col)
+						result.Extracted = append(result.Extracted, merged)
+					}
+				}
+				i = j
+			}
+		}
+		run = run[:0]
+	}
+
+	var err error
+	for {
+		var b byte
+		if b, err = br.ReadByte(); err != nil {
+			break
+		}
+		if isPrintable(b) {
+			if len(run) == 0 {
+				start = offset
+			}
+			run = append(run, b)
+		} else {
+			flush()
+			if b == '\n' {
+				line++
+			}
+		}
+		offset++
+	}
+	flush()
+	if err == io.EOF {
+		err = nil
+	}
+
+	if len(result.Lines) == 0 {
+		return nil, err
+	}
+	return &result, err
+}
+
+// SetBinaryMode selects how binary files are handled, skipping them by default
+func (res *ScanResult) SetBinaryMode(mode BinaryMode) {
+	res.mutex.Lock()
+	defer res.mutex.Unlock()
+	res.binary = mode
+}
+
+// scanReader sniffs r and scans it as text transcoded to UTF-8, as strings of
+// a binary, or not at all. A read error is returned with the findings made
+// before it.
+func (res *ScanResult) scanReader(r io.Reader, filter Filter) (*CodeLine, error) {
+	br := bufio.NewReaderSize(r, sniffLen)
+	head, _ := br.Peek(sniffLen)
+	enc, bom := DetectEncoding(head)
+	// UTF-16 is full of NUL bytes, so it is only sniffed for its encoding
+	if enc != EncodingUTF16LE && enc != EncodingUTF16BE {
+		if binary, _ := SniffBinary(head); binary {
+			if res.binary != BinaryStrings {
+				return nil, nil
+			}
+			return scanStrings(br, filter)
+		}
+	}
+	br.Discard(bom)
+	return scanLines(decodeReader(br, enc), filter, enc, bom)
+}
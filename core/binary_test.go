@@ -0,0 +1,56 @@
+package core
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestSniffBinary(t *testing.T) {
+	tests := []struct {
+		name string
+		head []byte
+		want bool
+	}{
+		{"go source", []byte("package main\n\nfunc main() {}\n"), false},
+		{"json", []byte(`{"key": "value"}`), false},
+		{"utf-8 text", []byte("héllo wörld\n"), false},
+		{"png", []byte("\x89PNG\r\n\x1a\n\x00\x00\x00\rIHDR"), true},
+		{"sqlite", []byte("SQLite format 3\x00\x10\x00"), true},
+		{"nul bytes", []byte("abc\x00def"), true},
+		{"woff2", []byte("wOF2\x00\x01"), true},
+		{"opentype", []byte("OTTO\x00\x0b\x00\x80"), true},
+		{"env starting like a magic number", []byte("OTTO_API_KEY=k8Vq2Lr9Zx4Pw7Mn3\nRIFF_MODE=on\n"), false},
+		{"text starting like id3", []byte("ID3 tags are read by the player\n"), false},
+		{"empty", nil, false},
+	}
+	for _, tt := range tests {
+		if got, _ := SniffBinary(tt.head); got != tt.want {
+			t.Errorf("%s: SniffBinary = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestPerLineScan_BinaryModes(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "cache.db")
+	content := "SQLite format 3\x00\x00\x01\x02token=k8Vq2Lr9Zx4Pw7Mn3Tb6Yc1Hd5Gf0Js\x00\xff\xfe"
+	os.WriteFile(file, []byte(content), 0644)
+
+	result := &ScanResult{}
+	result.Init()
+	if lines := result.PerLineScan(file, EntropyFilter(3.0)); lines != nil {
+		t.Errorf("binary files should be skipped by default, got %+v", lines)
+	}
+
+	result.SetBinaryMode(BinaryStrings)
+	lines := result.PerLineScan(file, EntropyFilter(3.0))
+	if lines == nil || len(lines.Lines) != 1 {
+		t.Fatalf("strings mode should find the embedded token, got %+v", lines)
+	}
+	if lines.Lines[0] != "token=k8Vq2Lr9Zx4Pw7Mn3Tb6Yc1Hd5Gf0Js" {
+		t.Errorf("unexpected token %q", lines.Lines[0])
+	}
+	if lines.Extracted[0]["offset"] != "19" {
+		t.Errorf("unexpected offset %q", lines.Extracted[0]["offset"])
+	}
+}
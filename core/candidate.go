@@ -0,0 +1,166 @@
+package core
+
+import (
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+// Candidate is a token handed to a Filter along with where it was found
+type Candidate struct {
+	Text string
+	// Path is the file, or the virtual path of an archive entry or notebook
+	// cell, the token comes from
+	Path string
+	// Language is the grammar name of the file, such as python, or "" when
+	// it is not known
+	Language string
+	// NodeKind is the syntax tree node type of the token, such as
+	// string_content, or "" when the file was not parsed
+	NodeKind string
+	// Parent is the identifier the token is assigned or keyed to: a
+	// variable, field or argument name, or the key_path of a value in a
+	// structured file. It is "" when there is none or it is not known.
+	Parent string
+	// Line and Column are the 1-based line and byte column of the token in
+	// the scanned text, 0 when not known
+	Line   int
+	Column int
+	// Source is the line of the scanned text the token starts on, so rules
+	// can match the assignment around it. It is "" when not known or the
+	// line is too long to hold.
+	Source string
+}
+
+// Filter checks candidates with their context, returning a Payload and
+// whether the candidate matched
+type Filter interface {
+	Check(c Candidate) (Payload, bool)
+}
+
+// FilterFunc adapts a function to a Filter
+type FilterFunc func(c Candidate) (Payload, bool)
+
+// Check calls f
+func (f FilterFunc) Check(c Candidate) (Payload, bool) {
+	return f(c)
+}
+
+// Check makes every LineFilter a Filter looking at the candidate text only
+func (f LineFilter) Check(c Candidate) (Payload, bool) {
+	return f(c.Text)
+}
+
+// TextFilter adapts a Filter to a LineFilter, checking text without context
+func TextFilter(f Filter) LineFilter {
+	if isNilFilter(f) {
+		return nil
+	}
+	return func(s string) (Payload, bool) {
+		return f.Check(Candidate{Text: s})
+	}
+}
+
+// isNilFilter reports whether f is missing, including a nil LineFilter or
+// FilterFunc converted to a Filter
+func isNilFilter(f Filter) bool {
+	switch f := f.(type) {
+	case nil:
+		return true
+	case LineFilter:
+		return f == nil
+	case FilterFunc:
+		return f == nil
+	}
+	return false
+}
+
+// AnyOf returns a filter that matches if any filter matches, merging their
+// payloads like AnyFilters. Nil filters are skipped.
+func AnyOf(filters ...Filter) Filter {
+	return FilterFunc(func(c Candidate) (Payload, bool) {
+		merged := make(Payload)
+		matched := false
+		for _, f := range filters {
+			if isNilFilter(f) {
+				continue
+			}
+			if pl, ok := f.Check(c); ok {
+				matched = true
+				for k, v := range pl {
+					merged[k] = v
+				}
+			}
+		}
+		if matched {
+			return merged, true
+		}
+		return nil, false
+	})
+}
+
+// AllOf returns a filter that matches only if every filter matches, merging
+// their payloads like AllFilters. Nil filters are skipped, and nothing
+// matches when all of them are nil.
+func AllOf(filters ...Filter) Filter {
+	return FilterFunc(func(c Candidate) (Payload, bool) {
+		merged := make(Payload)
+		checked := false
+		for _, f := range filters {
+			if isNilFilter(f) {
+				continue
+			}
+			pl, ok := f.Check(c)
+			if !ok {
+				return nil, false
+			}
+			checked = true
+			for k, v := range pl {
+				merged[k] = v
+			}
+		}
+		if !checked {
+			return nil, false
+		}
+		return merged, true
+	})
+}
+
+var (
+	bundledGrammarsOnce sync.Once
+	bundledGrammars     *GrammarConfig
+)
+
+// LanguageOf returns the grammar name of a file from the bundled grammar
+// map, e.g. python for main.py, or "" when none covers it. Archive entries
+// are named after the entry, notebook cells after the notebook.
+func LanguageOf(path string) string {
+	bundledGrammarsOnce.Do(func() {
+		bundledGrammars, _ = BundledGrammarConfig()
+	})
+	if bundledGrammars == nil {
+		return ""
+	}
+	name := NotebookFile(path)
+	if i := strings.LastIndex(name, ArchiveEntrySep); i >= 0 {
+		name = name[i+len(ArchiveEntrySep):]
+	}
+	return languageOf(bundledGrammars, filepath.Base(name))
+}
+
+// inFile fills the path and language of the candidates f receives, unless
+// the scanner already set them
+func inFile(f Filter, path string, language string) Filter {
+	if isNilFilter(f) {
+		return nil
+	}
+	return FilterFunc(func(c Candidate) (Payload, bool) {
+		if c.Path == "" {
+			c.Path = path
+		}
+		if c.Language == "" {
+			c.Language = language
+		}
+		return f.Check(c)
+	})
+}
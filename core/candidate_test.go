@@ -0,0 +1,84 @@
+package core
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestLineFilterAdapter(t *testing.T) {
+	const secret = "k8Vq2Lr9Zx4Pw7Mn3Tb6Yc1Hd5Gf0Js"
+	tests := []struct {
+		name   string
+		filter Filter
+		text   string
+		want   bool
+	}{
+		{"entropy", EntropyFilter(4.0), secret, true},
+		{"basic", BasicFilter(), "Abcdef123456!xyz", true},
+		{"any", AnyFilters(EntropyFilter(10), BasicFilter()), "Abcdef123456!xyz", true},
+		{"all", AllFilters(EntropyFilter(10), BasicFilter()), "Abcdef123456!xyz", false},
+		{"any of mixed", AnyOf(EntropyFilter(10), FilterFunc(func(c Candidate) (Payload, bool) {
+			return Payload{"path": c.Path}, c.Path == "app.py"
+		})), "x", true},
+		{"all of skips nil", AllOf(LineFilter(nil), nil, EntropyFilter(4.0)), secret, true},
+		{"all of nothing", AllOf(LineFilter(nil), nil), secret, false},
+	}
+	for _, tt := range tests {
+		if _, got := tt.filter.Check(Candidate{Text: tt.text, Path: "app.py"}); got != tt.want {
+			t.Errorf("%s: Check = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+
+	if pl, ok := TextFilter(AnyOf(EntropyFilter(4.0)))(secret); !ok || pl["entropy"] == "" {
+		t.Errorf("TextFilter should keep the payload, got %v", pl)
+	}
+	if TextFilter(LineFilter(nil)) != nil {
+		t.Error("TextFilter of a nil filter should be nil")
+	}
+}
+
+func TestCandidateContext(t *testing.T) {
+	dir := t.TempDir()
+	var seen []Candidate
+	record := FilterFunc(func(c Candidate) (Payload, bool) {
+		if c.Text == archiveSecret {
+			seen = append(seen, c)
+			return Payload{"seen": "true"}, true
+		}
+		return nil, false
+	})
+
+	script := filepath.Join(dir, "deploy.py")
+	os.WriteFile(script, []byte("import os\n\ntoken =  "+archiveSecret+"\n"), 0644)
+	config := filepath.Join(dir, "values.yaml")
+	os.WriteFile(config, []byte("db:\n  password: "+archiveSecret+"\n"), 0644)
+	bundle := filepath.Join(dir, "bundle.zip")
+	os.WriteFile(bundle, zipBytes(t, map[string][]byte{"lib/app.py": []byte(archiveSecret + "\n")}), 0644)
+
+	var res ScanResult
+	res.Init()
+	res.PerLineScan(script, record)
+	if _, err := res.ScanStructured(config, record); err != nil {
+		t.Fatal(err)
+	}
+	if err := res.ScanArchive(bundle, record, 1<<20); err != nil {
+		t.Fatal(err)
+	}
+
+	want := []Candidate{
+		{Text: archiveSecret, Path: script, Language: "python", Line: 3, Column: 10, Source: "token =  " + archiveSecret},
+		{Text: archiveSecret, Path: config, Language: "yaml", Parent: "db.password", Line: 2, Column: 13},
+		// the line scan of comments and keys sees the value again, without its key
+		{Text: archiveSecret, Path: config, Language: "yaml", Line: 2, Column: 13, Source: "  password: " + archiveSecret},
+		{Text: archiveSecret, Path: bundle + ArchiveEntrySep + "lib/app.py", Language: "python", Line: 1, Column: 1, Source: archiveSecret},
+	}
+	if len(seen) != len(want) {
+		t.Fatalf("expected %d candidates, got %+v", len(want), seen)
+	}
+	for i := range want {
+		if seen[i] != want[i] {
+			t.Errorf("candidate %d = %+v, want %+v", i, seen[i], want[i])
+		}
+	}
+}
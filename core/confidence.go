@@ -0,0 +1,139 @@
+package core
+
+import (
+	"fmt"
+	"math"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// Severity ranks how damaging a leaked secret would be
+type Severity string
+
+const (
+	SeverityLow      Severity = "low"
+	SeverityMedium   Severity = "medium"
+	SeverityHigh     Severity = "high"
+	SeverityCritical Severity = "critical"
+)
+
+// severityRank orders severities, unknown ones rank below low
+var severityRank = map[Severity]int{
+	SeverityLow: 1, SeverityMedium: 2, SeverityHigh: 3, SeverityCritical: 4,
+}
+
+// ParseSeverity reads a severity name, case-insensitively
+func ParseSeverity(name string) (Severity, error) {
+	s := Severity(strings.ToLower(strings.TrimSpace(name)))
+	if _, ok := severityRank[s]; !ok {
+		return "", fmt.Errorf("unknown severity %q, expected low, medium, high or critical", name)
+	}
+	return s, nil
+}
+
+// AtLeast reports whether s is as severe as min or more
+func (s Severity) AtLeast(min Severity) bool {
+	return severityRank[s] >= severityRank[min]
+}
+
+// Weights of the signals combined into a confidence
+const (
+	ruleConfidence    = 0.6  // a named rule matched
+	entropyConfidence = 0.3  // only the entropy threshold was crossed
+	entropyWeight     = 0.2  // scaled by how far entropy is above 3 bits
+	keywordWeight     = 0.15 // the token or its key names a credential
+	placeholderCap    = 0.1  // ceiling for findings that look like placeholders
+)
+
+// contextKeywordRe finds words naming a credential around a finding
+var contextKeywordRe = regexp.MustCompile(`(?i)secret|passw|pwd|token|api[-_.]?key|access[-_.]?key|private|credential|auth`)
+
+// Scoring configures how findings are scored and which are kept
+type Scoring struct {
+	// Rules maps the payload key of each rule to its severity. A finding
+	// matching none of them crossed the entropy threshold only.
+	Rules map[string]Severity
+	// MinConfidence drops findings scored below it
+	MinConfidence float64
+}
+
+// score returns the confidence and severity of a finding. It combines the
+// rules that matched, the entropy of the token and credential keywords in the
+// token or its key_path.
+func (s Scoring) score(text string, pl Payload) (float64, Severity) {
+	confidence, severity := entropyConfidence, SeverityMedium
+	for key, sev := range s.Rules {
+		if _, ok := pl[key]; !ok {
+			continue
+		}
+		confidence = ruleConfidence
+		if sev.AtLeast(severity) {
+			severity = sev
+		}
+	}
+
+	e, err := strconv.ParseFloat(pl["entropy"], 64)
+	if err != nil {
+		e = CalcEntropy(text)
+	}
+	confidence += entropyWeight * math.Min(math.Max((e-3)/2, 0), 1)
+	if contextKeywordRe.MatchString(text) || contextKeywordRe.MatchString(pl["key_path"]) {
+		confidence += keywordWeight
+	}
+
+	if pl["placeholder"] != "" {
+		confidence = math.Min(confidence, placeholderCap)
+		severity = SeverityLow
+	}
+	return math.Round(math.Min(confidence, 1)*100) / 100, severity
+}
+
+// ScoreFindings records the confidence and severity of every finding in its
+// payload and drops the findings below s.MinConfidence, returning how many
+// were dropped
+func (res *ScanResult) ScoreFindings(s Scoring) int {
+	res.mutex.Lock()
+	defer res.mutex.Unlock()
+
+	dropped := 0
+	for path, lines := range res.filenameMap {
+		for len(lines.Extracted) < len(lines.Lines) {
+			lines.Extracted = append(lines.Extracted, nil)
+		}
+		kept := lines.keep(func(i int) bool {
+			if lines.Extracted[i] == nil {
+				lines.Extracted[i] = Payload{}
+			}
+			pl := lines.Extracted[i]
+			confidence, severity := s.score(lines.Lines[i], pl)
+			pl["confidence"] = strconv.FormatFloat(confidence, 'f', 2, 64)
+			pl["severity"] = string(severity)
+			if confidence < s.MinConfidence {
+				dropped++
+				return false
+			}
+			return true
+		})
+		if len(kept.Lines) == 0 {
+			delete(res.filenameMap, path)
+		} else {
+			res.filenameMap[path] = kept
+		}
+	}
+	return dropped
+}
+
+// HasSeverity reports whether any finding is at least as severe as min
+func (res *ScanResult) HasSeverity(min Severity) bool {
+	res.mutex.RLock()
+	defer res.mutex.RUnlock()
+	for _, lines := range res.filenameMap {
+		for _, pl := range lines.Extracted {
+			if pl != nil && Severity(pl["severity"]).AtLeast(min) {
+				return true
+			}
+		}
+	}
+	return false
+}
@@ -0,0 +1,348 @@
+package core
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io"
+	"unicode/utf16"
+	"unicode/utf8"
+)
+
+// TextEncoding is the character encoding a file is transcoded from before scanning
+type TextEncoding string
+
+// Encodings recognised by DetectEncoding
+const (
+	EncodingUTF8    TextEncoding = "utf-8"
+	EncodingUTF16LE TextEncoding = "utf-16le"
+	EncodingUTF16BE TextEncoding = "utf-16be"
+	EncodingLatin1  TextEncoding = "latin-1"
+)
+
+// byteOrderMarks are the BOMs recognised at the start of a file
+var byteOrderMarks = []struct {
+	bom []byte
+	enc TextEncoding
+}{
+	{[]byte("\xef\xbb\xbf"), EncodingUTF8},
+	{[]byte("\xff\xfe"), EncodingUTF16LE},
+	{[]byte("\xfe\xff"), EncodingUTF16BE},
+}
+
+// DetectEncoding guesses the encoding of text from its head, returning the
+// length of its byte order mark. Without a BOM, UTF-16 is recognised by NUL
+// bytes at every other position and text that is not valid UTF-8 is taken
+// as Latin-1, so binary content must be ruled out by the caller.
+func DetectEncoding(head []byte) (TextEncoding, int) {
+	for _, m := range byteOrderMarks {
+		if bytes.HasPrefix(head, m.bom) {
+			return m.enc, len(m.bom)
+		}
+	}
+	if len(head) > sniffLen {
+		head = head[:sniffLen]
+	}
+	if enc, ok := sniffUTF16(head); ok {
+		return enc, 0
+	}
+	if !validUTF8Prefix(head) {
+		return EncodingLatin1, 0
+	}
+	return EncodingUTF8, 0
+}
+
+// sniffUTF16 recognises BOM-less UTF-16 text, mostly ASCII, by its NUL bytes
+func sniffUTF16(head []byte) (TextEncoding, bool) {
+	if len(head) < 4 || magicKind(head) != "" {
+		return "", false
+	}
+	var even, odd int
+	for i, b := range head {
+		if b != 0 {
+			continue
+		}
+		if i%2 == 0 {
+			even++
+		} else {
+			odd++
+		}
+	}
+	pairs := len(head) / 2
+	switch {
+	case odd*10 > pairs*4 && even*10 < pairs:
+		return EncodingUTF16LE, true
+	case even*10 > pairs*4 && odd*10 < pairs:
+		return EncodingUTF16BE, true
+	}
+	return "", false
+}
+
+// validUTF8Prefix reports whether head is valid UTF-8, allowing it to end in
+// the middle of a character
+func validUTF8Prefix(head []byte) bool {
+	if utf8.Valid(head) {
+		return true
+	}
+	for k := 1; k < utf8.UTFMax && k <= len(head); k++ {
+		cut := len(head) - k
+		if !utf8.FullRune(head[cut:]) && utf8.Valid(head[:cut]) {
+			return true
+		}
+	}
+	return false
+}
+
+// originalLen returns how many bytes of the original encoding the UTF-8
+// text b was transcoded from
+func (e TextEncoding) originalLen(b []byte) int {
+	switch e {
+	case EncodingUTF16LE, EncodingUTF16BE:
+		n := 0
+		for _, c := range b {
+			switch {
+			case c >= 0xf0:
+				n += 4
+			case c < 0x80 || c >= 0xc0:
+				n += 2
+			}
+		}
+		return n
+	case EncodingLatin1:
+		n := 0
+		for _, c := range b {
+			if c < 0x80 || c >= 0xc0 {
+				n++
+			}
+		}
+		return n
+	}
+	return len(b)
+}
+
+// decodeReader transcodes r from enc to UTF-8, r must be past any BOM
+func decodeReader(r io.Reader, enc TextEncoding) io.Reader {
+	switch enc {
+	case EncodingUTF16LE:
+		return &utf16Reader{r: r, order: binary.LittleEndian, held: -1}
+	case EncodingUTF16BE:
+		return &utf16Reader{r: r, order: binary.BigEndian, held: -1}
+	case EncodingLatin1:
+		return &latin1Reader{r: r}
+	}
+	return r
+}
+
+// utf16Reader transcodes UTF-16 to UTF-8, replacing unpaired surrogates
+type utf16Reader struct {
+	r     io.Reader
+	order binary.ByteOrder
+	held  rune // unit read ahead while pairing surrogates, -1 if none
+	out   []byte
+	err   error
+}
+
+// next reads one UTF-16 code unit
+func (u *utf16Reader) next() (rune, error) {
+	if u.held >= 0 {
+		c := u.held
+		u.held = -1
+		return c, nil
+	}
+	var unit [2]byte
+	if _, err := io.ReadFull(u.r, unit[:]); err != nil {
+		if err == io.ErrUnexpectedEOF {
+			err = io.EOF
+		}
+		return 0, err
+	}
+	return rune(u.order.Uint16(unit[:])), nil
+}
+
+func (u *utf16Reader) Read(p []byte) (int, error) {
+	for len(u.out) < len(p) && u.err == nil {
+		c, err := u.next()
+		if err != nil {
+			u.err = err
+			break
+		}
+		if utf16.IsSurrogate(c) {
+			low, err := u.next()
+			if err != nil {
+				u.err = err
+				c = utf8.RuneError
+			} else if r := utf16.DecodeRune(c, low); r != utf8.RuneError {
+				c = r
+			} else {
+				u.held, c = low, utf8.RuneError
+			}
+		}
+		u.out = utf8.AppendRune(u.out, c)
+	}
+	if len(u.out) == 0 {
+		return 0, u.err
+	}
+	n := copy(p, u.out)
+	u.out = u.out[n:]
+	return n, nil
+}
+
+// latin1Reader transcodes ISO-8859-1 to UTF-8
+type latin1Reader struct {
+	r   io.Reader
+	raw [4096]byte
+	out []byte
+	err error
+}
+
+func (l *latin1Reader) Read(p []byte) (int, error) {
+	if len(l.out) == 0 && l.err == nil {
+		var n int
+		n, l.err = l.r.Read(l.raw[:])
+		for _, b := range l.raw[:n] {
+			l.out = utf8.AppendRune(l.out, rune(b))
+		}
+	}
+	if len(l.out) == 0 {
+		return 0, l.err
+	}
+	n := copy(p, l.out)
+	l.out = l.out[n:]
+	return n, nil
+}
+
+// DecodeText transcodes a whole file to UTF-8, returning the encoding it was
+// read as and the length of the BOM dropped from it
+func DecodeText(data []byte) ([]byte, TextEncoding, int) {
+	enc, bom := DetectEncoding(data)
+	if enc == EncodingUTF8 {
+		return data[bom:], enc, bom
+	}
+	if enc == EncodingLatin1 {
+		if isBinary, _ := SniffBinary(data); isBinary {
+			return data, EncodingUTF8, 0
+		}
+	}
+	decoded, err := io.ReadAll(decodeReader(bytes.NewReader(data[bom:]), enc))
+	if err != nil {
+		return data, EncodingUTF8, 0
+	}
+	return decoded, enc, bom
+}
+
+// EncodeText transcodes UTF-8 text to enc, the inverse of DecodeText without
+// the BOM. Characters Latin-1 cannot hold are written as '?'.
+func EncodeText(text []byte, enc TextEncoding) []byte {
+	switch enc {
+	case EncodingUTF16LE, EncodingUTF16BE:
+		var order binary.AppendByteOrder = binary.LittleEndian
+		if enc == EncodingUTF16BE {
+			order = binary.BigEndian
+		}
+		out := make([]byte, 0, 2*len(text))
+		for _, unit := range utf16.Encode([]rune(string(text))) {
+			out = order.AppendUint16(out, unit)
+		}
+		return out
+	case EncodingLatin1:
+		out := make([]byte, 0, len(text))
+		for _, r := range string(text) {
+			if r > 0xff {
+				r = '?'
+			}
+			out = append(out, byte(r))
+		}
+		return out
+	}
+	return text
+}
+
+// decodeReversible decodes data like DecodeText, also reporting whether
+// encoding the text back gives data again, so it can be rewritten in place
+func decodeReversible(data []byte) ([]byte, TextEncoding, int, bool) {
+	text, enc, bom := DecodeText(data)
+	if enc == EncodingUTF8 {
+		return text, enc, bom, true
+	}
+	return text, enc, bom, bytes.Equal(EncodeText(text, enc), data[bom:])
+}
+
+// decodeAs transcodes data, past any BOM, from enc to UTF-8
+func decodeAs(data []byte, enc TextEncoding) ([]byte, error) {
+	return io.ReadAll(decodeReader(bytes.NewReader(data), enc))
+}
+
+// markEncoding records the encoding of a file that is not UTF-8 in the
+// payload of its findings, as the line scan does
+func markEncoding(lines *CodeLine, enc TextEncoding) {
+	if lines == nil || enc == EncodingUTF8 {
+		return
+	}
+	for i := range lines.Extracted {
+		if lines.Extracted[i] == nil {
+			lines.Extracted[i] = Payload{}
+		}
+		lines.Extracted[i]["encoding"] = string(enc)
+	}
+}
+
+// remapColumns turns byte columns of decoded code into byte columns of the
+// original file
+func remapColumns(lines *CodeLine, code []byte, enc TextEncoding, bom int) {
+	if lines == nil || (enc == EncodingUTF8 && bom == 0) {
+		return
+	}
+	starts := []int{0}
+	for i, b := range code {
+		if b == '\n' {
+			starts = append(starts, i+1)
+		}
+	}
+	for i, col := range lines.Columns {
+		row := lines.Indexes[i] - 1
+		if row < 0 || row >= len(starts) || col < 1 {
+			continue
+		}
+		end := starts[row] + col - 1
+		if end > len(code) {
+			continue
+		}
+		mapped := enc.originalLen(code[starts[row]:end]) + 1
+		if row == 0 {
+			mapped += bom
+		}
+		lines.Columns[i] = mapped
+	}
+}
+
+// decodedColumns turns the byte columns of findings in the original file back
+// into byte columns of its decoded code, the inverse of remapColumns
+func decodedColumns(lines CodeLine, code []byte, enc TextEncoding, bom int) CodeLine {
+	if enc == EncodingUTF8 && bom == 0 {
+		return lines
+	}
+	starts := lineOffsets(code)
+	columns := make([]int, len(lines.Columns))
+	for i, col := range lines.Columns {
+		columns[i] = col
+		if i >= len(lines.Indexes) {
+			continue
+		}
+		row := lines.Indexes[i] - 1
+		if row < 0 || row >= len(starts) || col < 1 {
+			continue
+		}
+		want := col - 1
+		if row == 0 {
+			want -= bom
+		}
+		pos, n := starts[row], 0
+		for pos < len(code) && code[pos] != '\n' && n < want {
+			_, size := utf8.DecodeRune(code[pos:])
+			n += enc.originalLen(code[pos : pos+size])
+			pos += size
+		}
+		columns[i] = pos - starts[row] + 1
+	}
+	lines.Columns = columns
+	return lines
+}
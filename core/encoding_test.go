@@ -0,0 +1,132 @@
+package core
+
+import (
+	"encoding/binary"
+	"os"
+	"path/filepath"
+	"testing"
+	"unicode/utf16"
+)
+
+// utf16Bytes encodes s as UTF-16 in the given byte order
+func utf16Bytes(s string, order binary.ByteOrder) []byte {
+	units := utf16.Encode([]rune(s))
+	out := make([]byte, 2*len(units))
+	for i, u := range units {
+		order.PutUint16(out[2*i:], u)
+	}
+	return out
+}
+
+func TestDetectEncoding(t *testing.T) {
+	tests := []struct {
+		name string
+		head []byte
+		enc  TextEncoding
+		bom  int
+	}{
+		{"plain utf-8", []byte("key = value\n"), EncodingUTF8, 0},
+		{"utf-8 bom", []byte("\xef\xbb\xbfkey = value\n"), EncodingUTF8, 3},
+		{"utf-16le bom", append([]byte("\xff\xfe"), utf16Bytes("key = value\n", binary.LittleEndian)...), EncodingUTF16LE, 2},
+		{"utf-16be bom", append([]byte("\xfe\xff"), utf16Bytes("key = value\n", binary.BigEndian)...), EncodingUTF16BE, 2},
+		{"utf-16le without bom", utf16Bytes("[settings]\r\npassword = hunter2\r\n", binary.LittleEndian), EncodingUTF16LE, 0},
+		{"utf-16be without bom", utf16Bytes("[settings]\r\npassword = hunter2\r\n", binary.BigEndian), EncodingUTF16BE, 0},
+		{"latin-1", []byte("mot de passe = caf\xe9\n"), EncodingLatin1, 0},
+		{"truetype is not utf-16", []byte("\x00\x01\x00\x00\x00\x10\x01\x00\x00\x04\x00\x00"), EncodingUTF8, 0},
+	}
+	for _, tt := range tests {
+		enc, bom := DetectEncoding(tt.head)
+		if enc != tt.enc || bom != tt.bom {
+			t.Errorf("%s: DetectEncoding = %s, %d; want %s, %d", tt.name, enc, bom, tt.enc, tt.bom)
+		}
+	}
+}
+
+func TestPerLineScan_Encodings(t *testing.T) {
+	const secret = "k8Vq2Lr9Zx4Pw7Mn3Tb6Yc1Hd5Gf0Js"
+	text := "# réglages\r\nkey = " + secret + "\r\nnom = é " + secret + "\r\n"
+
+	tests := []struct {
+		name    string
+		content []byte
+		enc     string
+		cols    []int
+	}{
+		{"utf-8", []byte(text), "", []int{7, 10}},
+		{"utf-8 bom", append([]byte("\xef\xbb\xbf"), text...), "", []int{7, 10}},
+		{"utf-16le bom", append([]byte("\xff\xfe"), utf16Bytes(text, binary.LittleEndian)...), "utf-16le", []int{13, 17}},
+		{"utf-16be", utf16Bytes(text, binary.BigEndian), "utf-16be", []int{13, 17}},
+		{"latin-1", []byte("# r\xe9glages\r\nkey = " + secret + "\r\nnom = \xe9 " + secret + "\r\n"), "latin-1", []int{7, 9}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			file := filepath.Join(t.TempDir(), "settings.ini")
+			os.WriteFile(file, tt.content, 0644)
+
+			result := &ScanResult{}
+			result.Init()
+			lines := result.PerLineScan(file, EntropyFilter(4.0))
+			if lines == nil || len(lines.Lines) != 2 {
+				t.Fatalf("expected two findings, got %+v", lines)
+			}
+			for i, want := range tt.cols {
+				if lines.Lines[i] != secret || lines.Indexes[i] != i+2 || lines.Columns[i] != want {
+					t.Errorf("finding %d: %q at %d:%d, want line %d col %d",
+						i, lines.Lines[i], lines.Indexes[i], lines.Columns[i], i+2, want)
+				}
+				if lines.Extracted[i]["encoding"] != tt.enc {
+					t.Errorf("finding %d: encoding %q, want %q", i, lines.Extracted[i]["encoding"], tt.enc)
+				}
+			}
+		})
+	}
+}
+
+func TestRemapColumns(t *testing.T) {
+	raw := append([]byte("\xff\xfe"), utf16Bytes("a = 1\nbé = secret\n", binary.LittleEndian)...)
+	code, enc, bom := DecodeText(raw)
+	if string(code) != "a = 1\nbé = secret\n" || enc != EncodingUTF16LE || bom != 2 {
+		t.Fatalf("DecodeText = %q, %s, %d", code, enc, bom)
+	}
+	lines := &CodeLine{Lines: []string{"1", "secret"}, Indexes: []int{1, 2}, Columns: []int{5, 7}}
+	remapColumns(lines, code, enc, bom)
+	if lines.Columns[0] != 11 || lines.Columns[1] != 11 {
+		t.Errorf("unexpected columns %v", lines.Columns)
+	}
+}
+
+func TestIterFiles_ParsedEncodings(t *testing.T) {
+	useCompiledGrammars(t)
+	const secret = "k8Vq2Lr9Zx4Pw7Mn3Tb6Yc1Hd5Gf0Js"
+	text := "# réglages\r\nkey = \"" + secret + "\"\r\n"
+
+	tests := []struct {
+		name    string
+		content []byte
+		enc     string
+		col     int
+	}{
+		{"utf-8", []byte(text), "", 8},
+		{"utf-16le bom", append([]byte("\xff\xfe"), utf16Bytes(text, binary.LittleEndian)...), "utf-16le", 15},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			file := filepath.Join(t.TempDir(), "settings.py")
+			os.WriteFile(file, tt.content, 0644)
+
+			result := &ScanResult{}
+			result.Init()
+			if err := result.IterFiles([]string{file}, EntropyFilter(4.0), 1<<20); err != nil {
+				t.Fatal(err)
+			}
+			lines, ok := result.filenameMap[file]
+			if !ok || len(lines.Lines) != 1 {
+				t.Fatalf("expected one finding, got %+v", lines)
+			}
+			if lines.Indexes[0] != 2 || lines.Columns[0] != tt.col || lines.Extracted[0]["encoding"] != tt.enc {
+				t.Errorf("finding at %d:%d with encoding %q, want 2:%d with %q",
+					lines.Indexes[0], lines.Columns[0], lines.Extracted[0]["encoding"], tt.col, tt.enc)
+			}
+		})
+	}
+}
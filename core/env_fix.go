@@ -0,0 +1,351 @@
+package core
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"unicode"
+
+	sitter "github.com/smacker/go-tree-sitter"
+)
+
+// EnvVar is a secret moved out of the source into the .env file
+type EnvVar struct {
+	Name  string
+	Value string
+}
+
+// EnvFix is the planned rewrite of a single file
+type EnvFix struct {
+	Path     string
+	Mode     os.FileMode
+	Original []byte
+	Updated  []byte
+	Vars     []EnvVar
+}
+
+// envLanguage describes how a language reads an environment variable
+type envLanguage struct {
+	lookup       func(name string) string
+	ensureImport func(content string) string
+}
+
+var envLanguages = map[string]envLanguage{
+	".go": {
+		lookup:       func(name string) string { return `os.Getenv("` + name + `")` },
+		ensureImport: ensureGoImport,
+	},
+	".py": {
+		lookup:       func(name string) string { return `os.environ["` + name + `"]` },
+		ensureImport: ensurePythonImport,
+	},
+	".js":  {lookup: jsLookup},
+	".jsx": {lookup: jsLookup},
+	".mjs": {lookup: jsLookup},
+	".cjs": {lookup: jsLookup},
+	".ts":  {lookup: jsLookup},
+	".tsx": {lookup: jsLookup},
+}
+
+func jsLookup(name string) string { return "process.env." + name }
+
+var (
+	assignTargetRe = regexp.MustCompile(`([A-Za-z_][A-Za-z0-9_]*)["'\]]?\s*(?::=|=|:)\s*$`)
+	goImportOsRe   = regexp.MustCompile(`(?m)^\s*(import\s+)?(\w+\s+)?"os"\s*$`)
+	goImportRe     = regexp.MustCompile(`(?m)^import\s*\(\s*$|^import\s+`)
+	goPackageRe    = regexp.MustCompile(`(?m)^package\s+\w+.*$`)
+	pyImportOsRe   = regexp.MustCompile(`(?m)^\s*import\s+os\s*$|^\s*import\s+.*\bos\b`)
+	pyImportRe     = regexp.MustCompile(`(?m)^(import|from)\s+`)
+)
+
+// SupportsEnvFix reports whether a file's language has an env lookup rewrite
+func SupportsEnvFix(filename string) bool {
+	if IsVirtualPath(filename) {
+		return false
+	}
+	_, ok := envLanguages[filepath.Ext(filename)]
+	return ok
+}
+
+// envName turns an identifier like apiKey or api-key into API_KEY
+func envName(ident string) string {
+	var b strings.Builder
+	runes := []rune(ident)
+	for i, r := range runes {
+		switch {
+		case r == '-' || r == '.' || r == ' ':
+			b.WriteByte('_')
+		case unicode.IsUpper(r) && i > 0 && (unicode.IsLower(runes[i-1]) || unicode.IsDigit(runes[i-1])):
+			b.WriteByte('_')
+			b.WriteRune(r)
+		default:
+			b.WriteRune(unicode.ToUpper(r))
+		}
+	}
+	return b.String()
+}
+
+// stringLiterals are the syntax node types of string literals, quotes included
+var stringLiterals = map[string]bool{
+	"interpreted_string_literal": true, "raw_string_literal": true,
+	"string": true, "template_string": true,
+}
+
+// constantContexts are the syntax node types whose string literals must stay
+// constant expressions or literals, so an env lookup would not compile: Go
+// constants, struct tags, imports and array lengths, Python match patterns,
+// and JSX attributes, TypeScript enums and literal types
+var constantContexts = map[string]bool{
+	"const_declaration": true, "field_declaration": true, "import_declaration": true, "array_type": true,
+	"case_pattern": true, "import_statement": true, "import_from_statement": true,
+	"jsx_attribute": true, "enum_declaration": true, "literal_type": true,
+}
+
+// literalBounds returns the byte range of the string literal node enclosing
+// the secret at offset, quotes included. Literals used as keys or in a
+// constant context are refused.
+func literalBounds(root *sitter.Node, content []byte, offset, length int) (int, int, bool) {
+	n := root.NamedDescendantForPointRange(pointAt(content, offset), pointAt(content, offset+length))
+	for n != nil && !stringLiterals[n.Type()] {
+		n = n.Parent()
+	}
+	if n == nil {
+		return 0, 0, false
+	}
+	if parent := n.Parent(); parent != nil {
+		if key := parent.ChildByFieldName("key"); key != nil && key.Equal(n) {
+			return 0, 0, false
+		}
+	}
+	for p := n.Parent(); p != nil; p = p.Parent() {
+		if constantContexts[p.Type()] {
+			return 0, 0, false
+		}
+	}
+	return int(n.StartByte()), int(n.EndByte()), true
+}
+
+// pointAt converts a byte offset into a tree-sitter row/column point
+func pointAt(content []byte, offset int) sitter.Point {
+	var row, col uint32
+	for _, b := range content[:offset] {
+		if b == '\n' {
+			row++
+			col = 0
+		} else {
+			col++
+		}
+	}
+	return sitter.Point{Row: row, Column: col}
+}
+
+func ensureGoImport(content string) string {
+	if goImportOsRe.MatchString(content) {
+		return content
+	}
+	if loc := goImportRe.FindStringIndex(content); loc != nil {
+		if strings.HasSuffix(strings.TrimSpace(content[loc[0]:loc[1]]), "(") {
+			return content[:loc[1]] + "\n\t\"os\"" + content[loc[1]:]
+		}
+		return content[:loc[0]] + "import \"os\"\n" + content[loc[0]:]
+	}
+	if loc := goPackageRe.FindStringIndex(content); loc != nil {
+		return content[:loc[1]] + "\n\nimport \"os\"" + content[loc[1]:]
+	}
+	return content
+}
+
+func ensurePythonImport(content string) string {
+	if pyImportOsRe.MatchString(content) {
+		return content
+	}
+	if loc := pyImportRe.FindStringIndex(content); loc != nil {
+		return content[:loc[0]] + "import os\n" + content[loc[0]:]
+	}
+
+	// Keep shebang, encoding comments and the module docstring first
+	lines := strings.SplitAfter(content, "\n")
+	i := 0
+	for i < len(lines) && strings.HasPrefix(lines[i], "#") {
+		i++
+	}
+	if i < len(lines) {
+		trimmed := strings.TrimSpace(lines[i])
+		for _, q := range []string{`"""`, `'''`} {
+			if !strings.HasPrefix(trimmed, q) {
+				continue
+			}
+			if strings.Count(trimmed, q) < 2 {
+				for i++; i < len(lines) && !strings.Contains(lines[i], q); i++ {
+				}
+			}
+			i++
+			break
+		}
+	}
+	if i > len(lines) {
+		i = len(lines)
+	}
+	head := strings.Join(lines[:i], "")
+	if head != "" && !strings.HasSuffix(head, "\n") {
+		head += "\n"
+	}
+	return head + "import os\n" + strings.Join(lines[i:], "")
+}
+
+// PlanEnvFix plans rewriting the detected secrets of a file into env lookups.
+// taken holds env names already in use and is updated with the new ones.
+func PlanEnvFix(filename string, line CodeLine, taken map[string]string) (*EnvFix, error) {
+	lang, ok := envLanguages[filepath.Ext(filename)]
+	if !ok {
+		return nil, fmt.Errorf("no env lookup known for %s", filename)
+	}
+	info, err := os.Stat(filename)
+	if err != nil {
+		return nil, err
+	}
+	content, err := os.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+	tree, code, _, _, err := CreateTree(filename)
+	if err != nil {
+		return nil, fmt.Errorf("unable to parse %s: %w", filename, err)
+	}
+	if string(code) != string(content) {
+		return nil, fmt.Errorf("%s is not UTF-8", filename)
+	}
+	entries, err := obfuscatePerLine(content, line)
+	if err != nil {
+		return nil, err
+	}
+
+	type rewrite struct {
+		start, end int
+		text       string
+	}
+	var rewrites []rewrite
+	var vars []EnvVar
+	lastEnd := -1
+
+	for _, e := range entries {
+		start, end, ok := literalBounds(tree.RootNode(), content, e.Offset, len(e.Original))
+		if !ok || start < lastEnd {
+			continue
+		}
+		// The literal must hold nothing but the secret
+		if strings.Trim(string(content[start:end]), "\"'`") != e.Original {
+			continue
+		}
+
+		lineStart := strings.LastIndexByte(string(content[:start]), '\n') + 1
+		name := fmt.Sprintf("GITAEGIS_SECRET_%d", e.Line)
+		if m := assignTargetRe.FindStringSubmatch(string(content[lineStart:start])); m != nil {
+			name = envName(m[1])
+		}
+		base := name
+		for n := 2; ; n++ {
+			value, used := taken[name]
+			if !used || value == e.Original {
+				break
+			}
+			name = fmt.Sprintf("%s_%d", base, n)
+		}
+		if _, used := taken[name]; !used {
+			vars = append(vars, EnvVar{Name: name, Value: e.Original})
+		}
+		taken[name] = e.Original
+
+		rewrites = append(rewrites, rewrite{start, end, lang.lookup(name)})
+		lastEnd = end
+	}
+	if len(rewrites) == 0 {
+		return nil, nil
+	}
+
+	sort.Slice(rewrites, func(a, b int) bool { return rewrites[a].start < rewrites[b].start })
+	var b strings.Builder
+	prev := 0
+	for _, r := range rewrites {
+		b.Write(content[prev:r.start])
+		b.WriteString(r.text)
+		prev = r.end
+	}
+	b.Write(content[prev:])
+
+	updated := b.String()
+	if lang.ensureImport != nil {
+		updated = lang.ensureImport(updated)
+	}
+
+	return &EnvFix{
+		Path:     filename,
+		Mode:     info.Mode().Perm(),
+		Original: content,
+		Updated:  []byte(updated),
+		Vars:     vars,
+	}, nil
+}
+
+// envQuote quotes a .env value when it holds characters a shell would split on,
+// preferring single quotes which dotenv parsers keep literal
+func envQuote(value string) string {
+	if !strings.ContainsAny(value, " \t#\"'\\$`") {
+		return value
+	}
+	if !strings.Contains(value, "'") {
+		return "'" + value + "'"
+	}
+	return `"` + strings.NewReplacer(`\`, `\\`, `"`, `\"`).Replace(value) + `"`
+}
+
+// LoadEnvNames reads the variable names and raw values already in a .env file
+func LoadEnvNames(envPath string) (map[string]string, []byte, error) {
+	names := make(map[string]string)
+	data, err := os.ReadFile(envPath)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return names, nil, nil
+		}
+		return nil, nil, err
+	}
+	for _, line := range strings.Split(string(data), "\n") {
+		line = strings.TrimSpace(strings.TrimPrefix(strings.TrimSpace(line), "export "))
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		if k, v, ok := strings.Cut(line, "="); ok {
+			names[strings.TrimSpace(k)] = strings.Trim(strings.TrimSpace(v), `"'`)
+		}
+	}
+	return names, data, nil
+}
+
+// AppendEnvVars returns the .env content with vars appended
+func AppendEnvVars(existing []byte, vars []EnvVar) []byte {
+	content := string(existing)
+	if len(content) > 0 && !strings.HasSuffix(content, "\n") {
+		content += "\n"
+	}
+	for _, v := range vars {
+		content += v.Name + "=" + envQuote(v.Value) + "\n"
+	}
+	return []byte(content)
+}
+
+// WriteEnvFixes writes the rewritten files, appends vars to the .env of root
+// and makes sure that .env is git-ignored.
+func WriteEnvFixes(root string, fixes []*EnvFix, envContent []byte) error {
+	for _, fix := range fixes {
+		if err := os.WriteFile(fix.Path, fix.Updated, fix.Mode); err != nil {
+			return fmt.Errorf("failed to write %s: %w", fix.Path, err)
+		}
+	}
+	if err := os.WriteFile(filepath.Join(root, ".env"), envContent, 0600); err != nil {
+		return fmt.Errorf("failed to write .env: %w", err)
+	}
+	return checkAddGitignore(root, ".env")
+}
@@ -0,0 +1,147 @@
+package core
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	sitter "github.com/smacker/go-tree-sitter"
+	"github.com/smacker/go-tree-sitter/golang"
+	"github.com/smacker/go-tree-sitter/javascript"
+	"github.com/smacker/go-tree-sitter/python"
+)
+
+// useCompiledGrammars parses Go, Python and JavaScript with the grammars
+// compiled into the test binary instead of loading grammar libraries, until
+// the test ends
+func useCompiledGrammars(t *testing.T) {
+	cfg, err := BundledGrammarConfig()
+	if err != nil {
+		t.Fatal(err)
+	}
+	sitterInit.Do(func() {})
+	prevMap, prevErr := SitterMap, sitterInitErr
+	SitterMap, sitterInitErr = cfg, nil
+	grammars := map[string]*sitter.Language{
+		"go.so":         golang.GetLanguage(),
+		"python.so":     python.GetLanguage(),
+		"javascript.so": javascript.GetLanguage(),
+	}
+	for file, lang := range grammars {
+		langCache.Store(file, lang)
+	}
+	t.Cleanup(func() {
+		SitterMap, sitterInitErr = prevMap, prevErr
+		for file := range grammars {
+			langCache.Delete(file)
+		}
+	})
+}
+
+func TestEnvName(t *testing.T) {
+	tests := map[string]string{
+		"apiKey":       "API_KEY",
+		"db-password":  "DB_PASSWORD",
+		"AWS_SECRET":   "AWS_SECRET",
+		"token2Secret": "TOKEN2_SECRET",
+	}
+	for in, want := range tests {
+		if got := envName(in); got != want {
+			t.Errorf("envName(%q) = %q, want %q", in, got, want)
+		}
+	}
+}
+
+func TestPlanEnvFix(t *testing.T) {
+	useCompiledGrammars(t)
+	tests := []struct {
+		name     string
+		file     string
+		content  string
+		wantLine string
+		wantImp  string
+	}{
+		{
+			"go",
+			"main.go",
+			"package main\n\nimport (\n\t\"fmt\"\n)\n\nvar apiKey = \"aB3$kL9@mX2#pQ5!rT8\"\n",
+			`var apiKey = os.Getenv("API_KEY")`,
+			"\t\"os\"",
+		},
+		{
+			"python",
+			"app.py",
+			"import sys\nDB_PASS = 'aB3$kL9@mX2#pQ5!rT8'\n",
+			`DB_PASS = os.environ["DB_PASS"]`,
+			"import os",
+		},
+		{
+			"javascript",
+			"app.js",
+			"const cfg = { token: \"aB3$kL9@mX2#pQ5!rT8\" };\n",
+			"const cfg = { token: process.env.TOKEN };",
+			"",
+		},
+		{
+			"go const",
+			"main.go",
+			"package main\n\nconst token = \"aB3$kL9@mX2#pQ5!rT8\"\n",
+			"",
+			"",
+		},
+		{
+			"go struct tag",
+			"main.go",
+			"package main\n\ntype T struct{ Key string `aB3$kL9@mX2#pQ5!rT8` }\n",
+			"",
+			"",
+		},
+		{
+			"python dict key",
+			"app.py",
+			"cfg = {'aB3$kL9@mX2#pQ5!rT8': 1}\n",
+			"",
+			"",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), tt.file)
+			os.WriteFile(path, []byte(tt.content), 0644)
+
+			line := CodeLine{Lines: []string{"aB3$kL9@mX2#pQ5!rT8"}, Indexes: []int{strings.Count(tt.content, "\n")}, Columns: []int{1}}
+			fix, err := PlanEnvFix(path, line, map[string]string{})
+			if tt.wantLine == "" {
+				if err != nil || fix != nil {
+					t.Errorf("literal should be left alone, got %v, %v", fix, err)
+				}
+				return
+			}
+			if err != nil || fix == nil {
+				t.Fatalf("PlanEnvFix failed: %v", err)
+			}
+			updated := string(fix.Updated)
+			if !strings.Contains(updated, tt.wantLine) {
+				t.Errorf("expected %q in:\n%s", tt.wantLine, updated)
+			}
+			if tt.wantImp != "" && !strings.Contains(updated, tt.wantImp) {
+				t.Errorf("expected import %q in:\n%s", tt.wantImp, updated)
+			}
+			if len(fix.Vars) != 1 || fix.Vars[0].Value != "aB3$kL9@mX2#pQ5!rT8" {
+				t.Errorf("unexpected vars %+v", fix.Vars)
+			}
+		})
+	}
+}
+
+func TestUnifiedDiff(t *testing.T) {
+	before := "a\nb\nc\nd\n"
+	after := "a\nB\nc\nd\ne\n"
+	got := UnifiedDiff("f.txt", []byte(before), []byte(after))
+	want := "--- a/f.txt\n+++ b/f.txt\n@@ -1,4 +1,5 @@\n a\n-b\n+B\n c\n d\n+e\n"
+	if got != want {
+		t.Errorf("UnifiedDiff mismatch:\n%s\nwant:\n%s", got, want)
+	}
+}
@@ -0,0 +1,170 @@
+package core
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	gitignore "github.com/sabhiram/go-gitignore"
+)
+
+// IgnoreFileName is the gitignore-syntax file listing paths gitaegis never scans
+const IgnoreFileName = ".gitaegisignore"
+
+// Sources of exclusion rules, reported by Explain
+const (
+	SourceDefault = "built-in default"
+	SourceAdded   = "added at runtime"
+)
+
+// AddExemptFrom adds an exclude glob, recording where it came from
+func (res *ScanResult) AddExemptFrom(pattern string, source string) {
+	res.mutex.Lock()
+	defer res.mutex.Unlock()
+	res.exempt[pattern] = source
+}
+
+// AddInclude adds a glob re-including files that an exclusion matched
+func (res *ScanResult) AddInclude(pattern string, source string) {
+	res.mutex.Lock()
+	defer res.mutex.Unlock()
+	res.include[pattern] = source
+}
+
+// SetRoot sets the directory exclusion globs are relative to and loads its
+// .gitaegisignore, if any
+func (res *ScanResult) SetRoot(root string) error {
+	abs, err := filepath.Abs(root)
+	if err != nil {
+		return err
+	}
+	var ign *gitignore.GitIgnore
+	path := filepath.Join(abs, IgnoreFileName)
+	if _, err := os.Stat(path); err == nil {
+		if ign, err = gitignore.CompileIgnoreFile(path); err != nil {
+			return fmt.Errorf("failed to load %s: %w", path, err)
+		}
+	}
+
+	res.mutex.Lock()
+	defer res.mutex.Unlock()
+	res.root = abs
+	res.ignoreFile = ign
+	return nil
+}
+
+// relPath returns filename relative to the exclusion root, slash separated
+func (res *ScanResult) relPath(filename string) string {
+	if res.root != "" {
+		if abs, err := filepath.Abs(filename); err == nil {
+			if rel, err := filepath.Rel(res.root, abs); err == nil && !strings.HasPrefix(rel, "..") {
+				return filepath.ToSlash(rel)
+			}
+		}
+	}
+	return strings.TrimPrefix(filepath.ToSlash(filepath.Clean(filename)), "./")
+}
+
+// matchingGlob returns the first glob of rules matching rel, in sorted order
+func matchingGlob(rules map[string]string, rel string) (string, bool) {
+	globs := make([]string, 0, len(rules))
+	for glob := range rules {
+		globs = append(globs, glob)
+	}
+	sort.Strings(globs)
+	for _, glob := range globs {
+		if MatchGlob(glob, rel) {
+			return glob, true
+		}
+	}
+	return "", false
+}
+
+// exemptReason describes the rule excluding a file, or returns "" when the
+// file is not excluded. Include globs win over every exclusion.
+func (res *ScanResult) exemptReason(filename string) string {
+	rel := res.relPath(filename)
+	if _, ok := matchingGlob(res.include, rel); ok {
+		return ""
+	}
+	if glob, ok := matchingGlob(res.exempt, rel); ok {
+		return fmt.Sprintf("excluded by %q (%s)", glob, res.exempt[glob])
+	}
+	if res.ignoreFile != nil {
+		if ok, how := res.ignoreFile.MatchesPathHow(rel); ok {
+			return fmt.Sprintf("excluded by %q (%s:%d)", how.Line, IgnoreFileName, how.LineNo)
+		}
+	}
+	return ""
+}
+
+// Explain reports whether IterFolder would scan a file, with the reasons
+// behind the decision in the order they are checked.
+func (res *ScanResult) Explain(filename string, useGitIgnore bool, maxFileSize int64) (bool, []string) {
+	res.mutex.RLock()
+	defer res.mutex.RUnlock()
+
+	info, err := os.Stat(filename)
+	if err != nil {
+		return false, []string{fmt.Sprintf("cannot be read: %v", err)}
+	}
+	if info.IsDir() {
+		return false, []string{"is a directory, files below it are explained individually"}
+	}
+
+	rel := res.relPath(filename)
+	if useGitIgnore {
+		start := res.root
+		if start == "" {
+			start = filepath.Dir(filename)
+		}
+		if ok, reason := NewGitIgnorer(start).Match(filename, false); ok {
+			return false, []string{reason}
+		}
+	}
+	var reasons []string
+	if glob, ok := matchingGlob(res.include, rel); ok {
+		reasons = append(reasons, fmt.Sprintf("included by %q (%s)", glob, res.include[glob]))
+	} else if reason := res.exemptReason(filename); reason != "" {
+		return false, []string{reason}
+	}
+	if isExecutable(filename) {
+		return false, append(reasons, "is executable")
+	}
+
+	if !res.scansArchive(filename) {
+		if f, err := os.Open(filename); err == nil {
+			head := make([]byte, sniffLen)
+			n, _ := io.ReadFull(f, head)
+			f.Close()
+			enc, _ := DetectEncoding(head[:n])
+			utf16 := enc == EncodingUTF16LE || enc == EncodingUTF16BE
+			if binary, kind := SniffBinary(head[:n]); binary && !utf16 {
+				if res.binary != BinaryStrings {
+					return false, append(reasons, fmt.Sprintf("binary content (%s)", kind))
+				}
+				reasons = append(reasons, fmt.Sprintf("binary content (%s), only printable strings are scanned", kind))
+			} else if enc != EncodingUTF8 {
+				reasons = append(reasons, fmt.Sprintf("%s text, transcoded to UTF-8", enc))
+			}
+		}
+	}
+
+	_, limit := res.settingsFor(filename, nil, maxFileSize)
+	if info.Size() > limit {
+		return false, append(reasons, fmt.Sprintf("size %d exceeds the limit of %d", info.Size(), limit))
+	}
+	if limit != maxFileSize {
+		reasons = append(reasons, fmt.Sprintf("override sets the size limit to %d", limit))
+	}
+	if IsNotebook(filename) {
+		reasons = append(reasons, fmt.Sprintf("notebook, cells and their outputs are reported as %sN", NotebookCellSep))
+	}
+	if format := StructuredFormat(filename); format != "" {
+		reasons = append(reasons, fmt.Sprintf("parsed as %s, findings carry their key path", format))
+	}
+	return true, append(reasons, "will be scanned")
+}
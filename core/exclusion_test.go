@@ -0,0 +1,62 @@
+package core
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestScanResult_Exclusions(t *testing.T) {
+	root := t.TempDir()
+	os.WriteFile(filepath.Join(root, IgnoreFileName), []byte("fixtures/\n*.pem\n!public.pem\n"), 0644)
+
+	result := &ScanResult{}
+	result.Init()
+	result.AddExemptFrom("build/**", "config")
+	result.AddInclude("build/keep.go", "config")
+	if err := result.SetRoot(root); err != nil {
+		t.Fatalf("SetRoot failed: %v", err)
+	}
+
+	tests := []struct {
+		path string
+		want bool
+	}{
+		{"go.sum", true},
+		{"backup/go.sum.bak", false},
+		{"src/latest/main.go", false},
+		{"build/out/app.js", true},
+		{"build/keep.go", false},
+		{"fixtures/user.json", true},
+		{"certs/server.pem", true},
+		{"certs/public.pem", false},
+	}
+	for _, tt := range tests {
+		if got := result.IsExempt(filepath.Join(root, tt.path)); got != tt.want {
+			t.Errorf("IsExempt(%q) = %v, want %v", tt.path, got, tt.want)
+		}
+	}
+
+	reason := result.exemptReason(filepath.Join(root, "certs", "server.pem"))
+	if !strings.Contains(reason, IgnoreFileName+":2") {
+		t.Errorf("reason should point at the ignore file line, got %q", reason)
+	}
+}
+
+func TestScanResult_Explain(t *testing.T) {
+	root := t.TempDir()
+	file := filepath.Join(root, "big.txt")
+	os.WriteFile(file, []byte(strings.Repeat("a", 100)), 0644)
+
+	result := &ScanResult{}
+	result.Init()
+	result.SetRoot(root)
+
+	if scanned, reasons := result.Explain(file, false, 10); scanned || !strings.Contains(reasons[0], "exceeds") {
+		t.Errorf("oversized file should not be scanned, got %v %v", scanned, reasons)
+	}
+	if scanned, _ := result.Explain(file, false, 1000); !scanned {
+		t.Error("file within the size limit should be scanned")
+	}
+}
@@ -8,8 +8,11 @@ import (
 	"os/user"
 	"path"
 	"path/filepath"
+	"sort"
 	"strings"
 	"time"
+
+	gitignore "github.com/sabhiram/go-gitignore"
 )
 
 type JsonMetadata struct {
@@ -89,119 +92,172 @@ func LoadFilenameMap(root string) (map[string]CodeLine, error) {
 	return topLevel.Data, nil
 }
 
-func checkAddGitignore(root string, filename string) error {
-	ignorePath := filepath.Join(root, ".gitignore")
-	var lines []string
-	if data, err := os.ReadFile(ignorePath); err == nil {
-		lines = strings.Split(string(data), "\n")
-		for _, line := range lines {
-			if strings.TrimSpace(line) == filename {
-				return nil
+// Markers delimiting the block of .gitignore owned by gitaegis
+const (
+	gitignoreBlockStart = "# >>> gitaegis >>>"
+	gitignoreBlockEnd   = "# <<< gitaegis <<<"
+)
+
+// GitignoreUpdate describes a planned or applied change to .gitignore
+type GitignoreUpdate struct {
+	Path      string
+	Before    []byte
+	After     []byte
+	Added     []string
+	Skipped   map[string]string
+	Files     []string // files of the repository the update covers, deduplicated
+	Untracked []string
+}
+
+// splitGitignore separates the user's lines from the entries of the managed block
+func splitGitignore(content string) (user []string, managed []string) {
+	inBlock := false
+	for _, line := range strings.Split(content, "\n") {
+		trimmed := strings.TrimSpace(line)
+		switch {
+		case trimmed == gitignoreBlockStart:
+			inBlock = true
+		case trimmed == gitignoreBlockEnd:
+			inBlock = false
+		case inBlock:
+			if trimmed != "" && !strings.HasPrefix(trimmed, "#") {
+				managed = append(managed, trimmed)
 			}
+		default:
+			user = append(user, line)
 		}
 	}
-
-	f, err := os.OpenFile(ignorePath, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
-	if err != nil {
-		return err
+	for len(user) > 0 && strings.TrimSpace(user[len(user)-1]) == "" {
+		user = user[:len(user)-1]
 	}
-	defer f.Close()
+	return user, managed
+}
 
-	if _, err := f.WriteString("# Added by gitaegis\n" + filename + "\n"); err != nil {
-		return err
+// renderGitignore writes the user's lines followed by the managed block
+func renderGitignore(user []string, managed []string) []byte {
+	var b strings.Builder
+	for _, line := range user {
+		b.WriteString(line)
+		b.WriteByte('\n')
 	}
-
-	return nil
+	if len(managed) > 0 {
+		if len(user) > 0 {
+			b.WriteByte('\n')
+		}
+		b.WriteString(gitignoreBlockStart + "\n")
+		b.WriteString("# Managed by gitaegis, this block is rewritten on update\n")
+		for _, entry := range managed {
+			b.WriteString(entry + "\n")
+		}
+		b.WriteString(gitignoreBlockEnd + "\n")
+	}
+	return []byte(b.String())
 }
 
-// UpdateGitignore appends all saved file paths from the filename map into .gitignore.
-// It ensures that previously detected files are ignored in Git.
-func UpdateGitignore(blob map[string]CodeLine) error {
-
-	if _, err := os.Stat(".gitignore"); os.IsNotExist(err) {
-		fmt.Println(".gitignore does not exist, creating it...")
+// PlanGitignore computes the .gitignore of root with paths added to the
+// managed block. Paths are made relative to root; entries already present or
+// already matched by the user's own patterns are skipped.
+func PlanGitignore(root string, paths []string) (*GitignoreUpdate, error) {
+	absRoot, err := filepath.Abs(root)
+	if err != nil {
+		return nil, err
+	}
+	update := &GitignoreUpdate{
+		Path:    filepath.Join(absRoot, ".gitignore"),
+		Skipped: make(map[string]string),
+	}
+	if data, err := os.ReadFile(update.Path); err == nil {
+		update.Before = data
+	} else if !os.IsNotExist(err) {
+		return nil, err
 	}
 
-	ignoreFile, err := os.OpenFile(".gitignore", os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
-	if err != nil {
-		return fmt.Errorf("unable to open .gitignore file: %w", err)
+	user, managed := splitGitignore(string(update.Before))
+	userIgnore := gitignore.CompileIgnoreLines(user...)
+	present := make(map[string]struct{}, len(managed))
+	for _, entry := range managed {
+		present[entry] = struct{}{}
 	}
-	defer ignoreFile.Close()
-	for filename := range blob {
-		if _, err := ignoreFile.WriteString(filename + "\n"); err != nil {
-			return fmt.Errorf("failed to write to .gitignore: %w", err)
+	files := make(map[string]struct{}, len(paths))
+
+	for _, p := range paths {
+		// Findings inside an archive or notebook ignore the file holding them
+		p = DiskFile(p)
+		abs := p
+		if !filepath.IsAbs(abs) {
+			abs = filepath.Join(absRoot, p)
+		}
+		rel, err := filepath.Rel(absRoot, abs)
+		if err != nil || rel == "." || strings.HasPrefix(rel, "..") {
+			update.Skipped[p] = "outside of repository"
+			continue
+		}
+		if _, ok := files[abs]; !ok {
+			files[abs] = struct{}{}
+			update.Files = append(update.Files, abs)
 		}
-		fmt.Printf("Added %s to .gitignore\n", filename)
+		entry := "/" + filepath.ToSlash(rel)
+		if _, ok := present[entry]; ok {
+			update.Skipped[entry] = "already in .gitignore"
+			continue
+		}
+		if userIgnore.MatchesPath(filepath.ToSlash(rel)) {
+			update.Skipped[entry] = "already matched by an existing pattern"
+			continue
+		}
+		present[entry] = struct{}{}
+		managed = append(managed, entry)
+		update.Added = append(update.Added, entry)
 	}
-	ignoreFile.Sync()
-	fmt.Println("Updated .gitignore successfully.")
-	return nil
+
+	sort.Strings(managed)
+	update.After = renderGitignore(user, managed)
+	return update, nil
 }
 
-// obfuscatePerLine replaces a specific line in a file with a warning marker.
-// This is used to "mask" secrets without deleting the file entirely.
-/*
+// WriteGitignore writes a planned update to disk
+func (u *GitignoreUpdate) Write() error {
+	return os.WriteFile(u.Path, u.After, 0644)
+}
 
-func obfuscatePerLine(filename string, line CodeLine) error {
-	content, err := os.ReadFile(filename)
+func checkAddGitignore(root string, filename string) error {
+	update, err := PlanGitignore(root, []string{filename})
 	if err != nil {
 		return err
 	}
-
-	buf := strings.Split(string(content), "\n")
-	if line.Index < 0 || line.Index >= len(buf) {
-		return fmt.Errorf("line index %d out of range for file %s", line.Index, filename)
+	if len(update.Added) == 0 {
+		return nil
 	}
-
-	buf[line.Index] = "===== gitaegis detected a secret: OBFUSCATED ====="
-	output := strings.Join(buf, "\n")
-	return os.WriteFile(filename, []byte(output), 0644)
+	return update.Write()
 }
 
-// obfuscates all lines previously detected and saved in the filename map.
-func LoadObfuscation(root string) error {
-	x, err := LoadFilenameMap(root)
-	if err != nil {
-		return fmt.Errorf("unable to load filename map: %w", err)
-	}
-	for k, v := range x {
-		for _, line := range v {
-			if err := obfuscatePerLine(k, line); err != nil {
-				return fmt.Errorf("failed to obfuscate file %s line %d: %v", k, line.Index, err)
-			}
-		}
+// UpdateGitignore adds the files of blob to the managed block of root's .gitignore.
+// With dryRun nothing is written; with untrack, files already tracked are also
+// removed from the index like `git rm --cached`.
+func UpdateGitignore(root string, blob map[string]CodeLine, dryRun bool, untrack bool) (*GitignoreUpdate, error) {
+	paths := make([]string, 0, len(blob))
+	for filename := range blob {
+		paths = append(paths, filename)
 	}
-	return nil
-}
+	sort.Strings(paths)
 
-// undoObfuscate restores all obfuscated lines back to their original content
-// using the saved filename map.
-func undoObfuscate(root string) error {
-	blob, err := LoadFilenameMap(root)
+	update, err := PlanGitignore(root, paths)
 	if err != nil {
-		return fmt.Errorf("unable to load filename map: %w", err)
+		return nil, err
 	}
-
-	for filename, lines := range blob {
-		content, err := os.ReadFile(filename)
-		if err != nil {
-			log.Printf("failed to read file %s: %v", filename, err)
-			continue
-		}
-
-		buf := strings.Split(string(content), "\n")
-		for _, line := range lines {
-			if line.Index >= 0 && line.Index < len(buf) {
-				buf[line.Index] = line.Line
-			}
+	if dryRun {
+		return update, nil
+	}
+	if len(update.Added) > 0 {
+		if err := update.Write(); err != nil {
+			return update, fmt.Errorf("failed to write .gitignore: %w", err)
 		}
-
-		output := strings.Join(buf, "\n")
-		if err := os.WriteFile(filename, []byte(output), 0644); err != nil {
-			log.Printf("failed to write file %s: %v", filename, err)
+	}
+	if untrack {
+		update.Untracked, err = GitRemoveCached(root, update.Files...)
+		if err != nil {
+			return update, fmt.Errorf("failed to untrack files: %w", err)
 		}
 	}
-
-	return nil
+	return update, nil
 }
-*/
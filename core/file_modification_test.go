@@ -0,0 +1,89 @@
+package core
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strings"
+	"testing"
+
+	git "github.com/go-git/go-git/v5"
+)
+
+func TestPlanGitignore(t *testing.T) {
+	root := t.TempDir()
+	os.WriteFile(filepath.Join(root, ".gitignore"), []byte("*.log\n"), 0644)
+
+	paths := []string{
+		filepath.Join(root, "config", "secret.go"),
+		filepath.Join(root, "debug.log"),
+		filepath.Join(root, "config", "secret.go"),
+		"/elsewhere/file.go",
+	}
+
+	update, err := PlanGitignore(root, paths)
+	if err != nil {
+		t.Fatalf("PlanGitignore failed: %v", err)
+	}
+	if len(update.Added) != 1 || update.Added[0] != "/config/secret.go" {
+		t.Errorf("expected only /config/secret.go to be added, got %v", update.Added)
+	}
+	if update.Skipped["/debug.log"] == "" {
+		t.Error("debug.log is already matched by *.log and should be skipped")
+	}
+	if update.Skipped["/elsewhere/file.go"] == "" {
+		t.Error("paths outside the root should be skipped")
+	}
+
+	if err := update.Write(); err != nil {
+		t.Fatalf("Write failed: %v", err)
+	}
+
+	// Rewriting the block must not duplicate entries
+	again, err := PlanGitignore(root, []string{filepath.Join(root, "config", "secret.go"), filepath.Join(root, "a.go")})
+	if err != nil {
+		t.Fatalf("PlanGitignore failed: %v", err)
+	}
+	content := string(again.After)
+	if strings.Count(content, "/config/secret.go") != 1 {
+		t.Errorf("entry duplicated:\n%s", content)
+	}
+	if strings.Count(content, gitignoreBlockStart) != 1 {
+		t.Errorf("managed block duplicated:\n%s", content)
+	}
+	if !strings.HasPrefix(content, "*.log\n") {
+		t.Errorf("user patterns should be kept first:\n%s", content)
+	}
+	if !strings.Contains(content, "/a.go\n/config/secret.go\n") {
+		t.Errorf("managed entries should be sorted:\n%s", content)
+	}
+}
+
+func TestUpdateGitignore_UntracksDiskFiles(t *testing.T) {
+	root := t.TempDir()
+	repo, err := git.PlainInit(root, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	os.WriteFile(filepath.Join(root, "lib.jar"), []byte("jar"), 0644)
+	os.WriteFile(filepath.Join(root, "nb.ipynb"), []byte("{}"), 0644)
+	wt, _ := repo.Worktree()
+	wt.Add("lib.jar")
+	wt.Add("nb.ipynb")
+
+	// Paths as the scan reports them, relative to root rather than the cwd
+	blob := map[string]CodeLine{
+		"lib.jar" + ArchiveEntrySep + "app.properties": {},
+		"nb.ipynb" + NotebookCellSep + "1":             {},
+		"nb.ipynb" + NotebookCellSep + "2":             {},
+	}
+	update, err := UpdateGitignore(root, blob, false, true)
+	if err != nil {
+		t.Fatalf("UpdateGitignore failed: %v", err)
+	}
+	sort.Strings(update.Untracked)
+	if !slices.Equal(update.Untracked, []string{"lib.jar", "nb.ipynb"}) {
+		t.Errorf("expected the archive and notebook to be untracked once, got %v", update.Untracked)
+	}
+}
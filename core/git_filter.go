@@ -0,0 +1,261 @@
+package core
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"sync"
+)
+
+// filterPlaceholderPrefix marks a secret replaced by the git clean filter
+const filterPlaceholderPrefix = "GITAEGIS_SECRET_"
+
+var filterPlaceholderRe = regexp.MustCompile(filterPlaceholderPrefix + `[0-9a-f]{16}`)
+
+// SecretStore keeps the secrets replaced by the clean filter so smudge can
+// restore them. It lives inside the git dir and is never committed.
+type SecretStore struct {
+	path    string
+	mutex   sync.Mutex
+	dirty   bool
+	Key     string            `json:"key"`
+	Secrets map[string]string `json:"secrets"`
+}
+
+// OpenSecretStore loads the store of the repository at root, creating a new
+// keyed store when none exists yet.
+func OpenSecretStore(root string) (*SecretStore, error) {
+	gitDir, err := GitDir(root)
+	if err != nil {
+		return nil, err
+	}
+	store := &SecretStore{
+		path:    filepath.Join(gitDir, "gitaegis", "secrets.json"),
+		Secrets: make(map[string]string),
+	}
+
+	data, err := os.ReadFile(store.path)
+	switch {
+	case err == nil:
+		if err := json.Unmarshal(data, store); err != nil {
+			return nil, fmt.Errorf("corrupt secret store %s: %w", store.path, err)
+		}
+		if store.Secrets == nil {
+			store.Secrets = make(map[string]string)
+		}
+	case os.IsNotExist(err):
+		key := make([]byte, 32)
+		if _, err := rand.Read(key); err != nil {
+			return nil, err
+		}
+		store.Key = hex.EncodeToString(key)
+		store.dirty = true
+	default:
+		return nil, err
+	}
+	return store, nil
+}
+
+// Placeholder returns the stable placeholder of a secret and remembers it.
+// Placeholders are keyed with the store's HMAC key so they cannot be
+// brute-forced back into short secrets from the committed content.
+func (s *SecretStore) Placeholder(secret string) string {
+	mac := hmac.New(sha256.New, []byte(s.Key))
+	mac.Write([]byte(secret))
+	ph := filterPlaceholderPrefix + hex.EncodeToString(mac.Sum(nil))[:16]
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if _, ok := s.Secrets[ph]; !ok {
+		s.Secrets[ph] = secret
+		s.dirty = true
+	}
+	return ph
+}
+
+// Lookup returns the secret stored for a placeholder
+func (s *SecretStore) Lookup(placeholder string) (string, bool) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	secret, ok := s.Secrets[placeholder]
+	return secret, ok
+}
+
+// Save persists the store with owner-only permissions when it changed. It
+// holds a lock next to the store and merges the secrets other processes
+// saved since it was opened, as git runs one clean filter per file.
+func (s *SecretStore) Save() error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if !s.dirty {
+		return nil
+	}
+	dir := filepath.Dir(s.path)
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return err
+	}
+	unlock, err := lockFile(s.path + ".lock")
+	if err != nil {
+		return fmt.Errorf("unable to lock secret store: %w", err)
+	}
+	defer unlock()
+
+	data, err := os.ReadFile(s.path)
+	switch {
+	case err == nil:
+		var saved SecretStore
+		if err := json.Unmarshal(data, &saved); err != nil {
+			return fmt.Errorf("corrupt secret store %s: %w", s.path, err)
+		}
+		// The first key saved wins, placeholders made with ours stay restorable
+		if saved.Key != "" {
+			s.Key = saved.Key
+		}
+		for ph, secret := range saved.Secrets {
+			if _, ok := s.Secrets[ph]; !ok {
+				s.Secrets[ph] = secret
+			}
+		}
+	case !os.IsNotExist(err):
+		return err
+	}
+
+	data, err = json.MarshalIndent(s, "", "  ")
+	if err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(dir, "secrets-*.json.tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp.Name(), s.path); err != nil {
+		return err
+	}
+	s.dirty = false
+	return nil
+}
+
+// fieldSpans returns the byte spans of the whitespace separated fields of line
+func fieldSpans(line string) [][2]int {
+	var spans [][2]int
+	start := -1
+	for i := 0; i < len(line); i++ {
+		c := line[i]
+		space := c == ' ' || c == '\t' || c == '\n' || c == '\r' || c == '\v' || c == '\f'
+		if space && start >= 0 {
+			spans = append(spans, [2]int{start, i})
+			start = -1
+		} else if !space && start < 0 {
+			start = i
+		}
+	}
+	if start >= 0 {
+		spans = append(spans, [2]int{start, len(line)})
+	}
+	return spans
+}
+
+// cleanLine replaces every secret of line with its placeholder
+func cleanLine(line string, filter Filter, store *SecretStore) string {
+	var out bytes.Buffer
+	prev := 0
+	for _, span := range fieldSpans(line) {
+		token := line[span[0]:span[1]]
+		if filterPlaceholderRe.MatchString(token) {
+			continue
+		}
+		if _, ok := filter.Check(Candidate{Text: token, Source: strings.TrimRight(line, "\r\n")}); !ok {
+			continue
+		}
+		secret := secretSpan(token)
+		at := strings.Index(token, secret)
+		if secret == "" || at < 0 {
+			continue
+		}
+		out.WriteString(line[prev : span[0]+at])
+		out.WriteString(store.Placeholder(secret))
+		prev = span[0] + at + len(secret)
+	}
+	out.WriteString(line[prev:])
+	return out.String()
+}
+
+// CleanStream is the git clean side: it copies r to w, replacing each
+// detected secret with a stable placeholder. UTF-16 and Latin-1 text is
+// cleaned as UTF-8 and encoded back, binary content passes through.
+func CleanStream(r io.Reader, w io.Writer, filter Filter, store *SecretStore) error {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return err
+	}
+	text, enc, bom, ok := decodeReversible(data)
+	if isNilFilter(filter) || !ok || bytes.IndexByte(text, 0) >= 0 {
+		_, err := w.Write(data)
+		return err
+	}
+
+	var out bytes.Buffer
+	out.Grow(len(text))
+	reader := bufio.NewReader(bytes.NewReader(text))
+	for {
+		line, err := reader.ReadString('\n')
+		if len(line) > 0 {
+			out.WriteString(cleanLine(line, filter, store))
+		}
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return err
+		}
+	}
+	if _, err := w.Write(encodeLike(data, out.Bytes(), enc, bom)); err != nil {
+		return err
+	}
+	return store.Save()
+}
+
+// encodeLike encodes UTF-8 text decoded from data back to enc, keeping the
+// BOM of data
+func encodeLike(data, text []byte, enc TextEncoding, bom int) []byte {
+	return append(data[:bom:bom], EncodeText(text, enc)...)
+}
+
+// SmudgeStream is the git smudge side: it copies r to w, putting back every
+// placeholder known to the store. Unknown placeholders are left untouched.
+func SmudgeStream(r io.Reader, w io.Writer, store *SecretStore) error {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return err
+	}
+	text, enc, bom, ok := decodeReversible(data)
+	if !ok {
+		text, enc, bom = data, EncodingUTF8, 0
+	}
+	out := filterPlaceholderRe.ReplaceAllFunc(text, func(ph []byte) []byte {
+		if secret, ok := store.Lookup(string(ph)); ok {
+			return []byte(secret)
+		}
+		return ph
+	})
+	_, err = w.Write(encodeLike(data, out, enc, bom))
+	return err
+}
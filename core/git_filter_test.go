@@ -0,0 +1,133 @@
+package core
+
+import (
+	"bytes"
+	"encoding/binary"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestCleanSmudge_RoundTrip(t *testing.T) {
+	root := t.TempDir()
+	os.Mkdir(filepath.Join(root, ".git"), 0755)
+
+	store, err := OpenSecretStore(root)
+	if err != nil {
+		t.Fatalf("OpenSecretStore failed: %v", err)
+	}
+
+	input := "package main\n\nvar apiKey = \"aB3$kL9@mX2#pQ5!rT8&nV1^wY4*uI7\"\nfunc main() {}\n"
+	var cleaned bytes.Buffer
+	if err := CleanStream(strings.NewReader(input), &cleaned, EntropyFilter(4.0), store); err != nil {
+		t.Fatalf("CleanStream failed: %v", err)
+	}
+	if strings.Contains(cleaned.String(), "aB3$kL9") {
+		t.Errorf("cleaned content still holds the secret: %q", cleaned.String())
+	}
+	if !strings.Contains(cleaned.String(), `var apiKey = "`+filterPlaceholderPrefix) {
+		t.Errorf("secret should be replaced in place: %q", cleaned.String())
+	}
+
+	// Placeholders must be stable so git sees no diff on re-clean
+	var again bytes.Buffer
+	CleanStream(strings.NewReader(input), &again, EntropyFilter(4.0), store)
+	if again.String() != cleaned.String() {
+		t.Error("clean should be deterministic")
+	}
+
+	reopened, err := OpenSecretStore(root)
+	if err != nil {
+		t.Fatalf("reopen store failed: %v", err)
+	}
+	var smudged bytes.Buffer
+	if err := SmudgeStream(&cleaned, &smudged, reopened); err != nil {
+		t.Fatalf("SmudgeStream failed: %v", err)
+	}
+	if smudged.String() != input {
+		t.Errorf("smudge should restore the original, got %q", smudged.String())
+	}
+
+	info, err := os.Stat(filepath.Join(root, ".git", "gitaegis", "secrets.json"))
+	if err != nil {
+		t.Fatalf("secret store should be saved: %v", err)
+	}
+	if info.Mode().Perm() != 0600 {
+		t.Errorf("secret store should be owner-only, got %v", info.Mode().Perm())
+	}
+}
+
+func TestCleanStream_BinaryPassthrough(t *testing.T) {
+	root := t.TempDir()
+	os.Mkdir(filepath.Join(root, ".git"), 0755)
+	store, _ := OpenSecretStore(root)
+
+	input := "aB3$kL9@mX2#pQ5!rT8&nV1^wY4*uI7\x00\x01"
+	var out bytes.Buffer
+	if err := CleanStream(strings.NewReader(input), &out, EntropyFilter(4.0), store); err != nil {
+		t.Fatalf("CleanStream failed: %v", err)
+	}
+	if out.String() != input {
+		t.Error("binary content should pass through unchanged")
+	}
+}
+
+func TestCleanSmudge_UTF16(t *testing.T) {
+	root := t.TempDir()
+	os.Mkdir(filepath.Join(root, ".git"), 0755)
+	store, _ := OpenSecretStore(root)
+
+	const secret = "aB3$kL9@mX2#pQ5!rT8&nV1^wY4*uI7"
+	input := append([]byte("\xff\xfe"), utf16Bytes("[db]\r\npassword="+secret+"\r\n", binary.LittleEndian)...)
+	var cleaned bytes.Buffer
+	if err := CleanStream(bytes.NewReader(input), &cleaned, EntropyFilter(4.0), store); err != nil {
+		t.Fatalf("CleanStream failed: %v", err)
+	}
+	text, enc, bom := DecodeText(cleaned.Bytes())
+	if enc != EncodingUTF16LE || bom != 2 || strings.Contains(string(text), secret) || !strings.Contains(string(text), "password="+filterPlaceholderPrefix) {
+		t.Errorf("expected the secret replaced in UTF-16LE text, got %s %q", enc, text)
+	}
+
+	var smudged bytes.Buffer
+	if err := SmudgeStream(&cleaned, &smudged, store); err != nil {
+		t.Fatalf("SmudgeStream failed: %v", err)
+	}
+	if !bytes.Equal(smudged.Bytes(), input) {
+		t.Errorf("smudge should restore the original, got %q", smudged.Bytes())
+	}
+}
+
+func TestSecretStore_ConcurrentSaves(t *testing.T) {
+	root := t.TempDir()
+	os.Mkdir(filepath.Join(root, ".git"), 0755)
+
+	// Two clean filters started before either saved
+	first, _ := OpenSecretStore(root)
+	second, _ := OpenSecretStore(root)
+	phFirst := first.Placeholder("aB3$kL9@mX2#pQ5!rT8")
+	phSecond := second.Placeholder("zY9&wV7^uT5%sR3#qP1")
+	if err := first.Save(); err != nil {
+		t.Fatal(err)
+	}
+	if err := second.Save(); err != nil {
+		t.Fatal(err)
+	}
+
+	reopened, err := OpenSecretStore(root)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := reopened.Lookup(phFirst); !ok {
+		t.Error("the second save dropped the secret of the first")
+	}
+	if _, ok := reopened.Lookup(phSecond); !ok {
+		t.Error("the secret of the second save is missing")
+	}
+	if reopened.Key != first.Key {
+		t.Error("the key saved first should be kept")
+	}
+	if matches, _ := filepath.Glob(filepath.Join(root, ".git", "gitaegis", "*.tmp")); len(matches) != 0 {
+		t.Errorf("temporary files left behind: %v", matches)
+	}
+}
@@ -1,9 +1,12 @@
 package core
 
 import (
+	"errors"
 	"fmt"
 	"log"
+	"os"
 	"path/filepath"
+	"strings"
 
 	git "github.com/go-git/go-git/v5"
 )
@@ -89,3 +92,99 @@ func GetUntrackedFile(path string) []string {
 
 	return files
 }
+
+// FindRepoRoot walks up from start to the directory containing .git
+func FindRepoRoot(start string) (string, error) {
+	dir, err := filepath.Abs(start)
+	if err != nil {
+		return "", err
+	}
+	for {
+		if _, err := os.Stat(filepath.Join(dir, ".git")); err == nil {
+			return dir, nil
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return "", errors.New("not inside a git repository")
+		}
+		dir = parent
+	}
+}
+
+// GitDir returns the git directory of the repository containing path,
+// following the `gitdir:` file used by worktrees and submodules.
+func GitDir(path string) (string, error) {
+	root, err := FindRepoRoot(path)
+	if err != nil {
+		return "", err
+	}
+	dotGit := filepath.Join(root, ".git")
+	info, err := os.Stat(dotGit)
+	if err != nil {
+		return "", err
+	}
+	if info.IsDir() {
+		return dotGit, nil
+	}
+	data, err := os.ReadFile(dotGit)
+	if err != nil {
+		return "", err
+	}
+	gitDir := strings.TrimSpace(strings.TrimPrefix(strings.TrimSpace(string(data)), "gitdir:"))
+	if !filepath.IsAbs(gitDir) {
+		gitDir = filepath.Join(root, gitDir)
+	}
+	return gitDir, nil
+}
+
+// RegisterFilterDriver sets filter.gitaegis.clean/smudge in the repository config
+func RegisterFilterDriver(repoPath string) error {
+	repo, err := git.PlainOpen(repoPath)
+	if err != nil {
+		return err
+	}
+	cfg, err := repo.Config()
+	if err != nil {
+		return err
+	}
+	sub := cfg.Raw.Section("filter").Subsection("gitaegis")
+	sub.SetOption("clean", "gitaegis filter clean %f")
+	sub.SetOption("smudge", "gitaegis filter smudge %f")
+	return repo.SetConfig(cfg)
+}
+
+// GitRemoveCached removes tracked paths from the index, keeping the worktree
+// files, like `git rm --cached`. It returns the paths that were untracked.
+func GitRemoveCached(repoPath string, paths ...string) ([]string, error) {
+	root, err := FindRepoRoot(repoPath)
+	if err != nil {
+		return nil, err
+	}
+	repo, err := git.PlainOpen(root)
+	if err != nil {
+		return nil, err
+	}
+	idx, err := repo.Storer.Index()
+	if err != nil {
+		return nil, err
+	}
+
+	var removed []string
+	for _, p := range paths {
+		abs, err := filepath.Abs(p)
+		if err != nil {
+			return removed, err
+		}
+		rel, err := filepath.Rel(root, abs)
+		if err != nil || strings.HasPrefix(rel, "..") {
+			continue
+		}
+		if _, err := idx.Remove(filepath.ToSlash(rel)); err == nil {
+			removed = append(removed, filepath.ToSlash(rel))
+		}
+	}
+	if len(removed) == 0 {
+		return nil, nil
+	}
+	return removed, repo.Storer.SetIndex(idx)
+}
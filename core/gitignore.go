@@ -0,0 +1,208 @@
+package core
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"github.com/go-git/go-git/v5/plumbing/format/config"
+	"github.com/go-git/go-git/v5/plumbing/format/gitignore"
+)
+
+// ignoreRule is a gitignore pattern with the file and line it was read from
+type ignoreRule struct {
+	pattern gitignore.Pattern
+	text    string
+	source  string
+}
+
+// dirVerdict caches whether a directory is ignored and by which rule
+type dirVerdict struct {
+	ignored bool
+	reason  string
+}
+
+// GitIgnorer applies git's ignore rules to paths below a repository root:
+// core.excludesFile, .git/info/exclude and the .gitignore of every directory,
+// with the closest .gitignore taking precedence. Patterns are matched with
+// go-git's gitignore matcher.
+type GitIgnorer struct {
+	root   string
+	global []ignoreRule
+	mu     sync.Mutex
+	dirs   map[string][]ignoreRule
+	seen   map[string]dirVerdict
+}
+
+// NewGitIgnorer loads the ignore rules of the repository containing start.
+// Outside a repository start itself is the root and only its .gitignore
+// files apply.
+func NewGitIgnorer(start string) *GitIgnorer {
+	root, err := FindRepoRoot(start)
+	if err != nil {
+		if root, err = filepath.Abs(start); err != nil {
+			root = start
+		}
+	}
+	g := &GitIgnorer{
+		root: root,
+		dirs: make(map[string][]ignoreRule),
+		seen: make(map[string]dirVerdict),
+	}
+	if path := excludesFile(root); path != "" {
+		g.global = append(g.global, readIgnoreRules(path, nil)...)
+	}
+	if gitDir, err := GitDir(root); err == nil {
+		g.global = append(g.global, readIgnoreRules(filepath.Join(commonGitDir(gitDir), "info", "exclude"), nil)...)
+	}
+	return g
+}
+
+// Root returns the directory ignore patterns are relative to
+func (g *GitIgnorer) Root() string {
+	return g.root
+}
+
+// commonGitDir resolves the shared git directory of a linked worktree
+func commonGitDir(gitDir string) string {
+	data, err := os.ReadFile(filepath.Join(gitDir, "commondir"))
+	if err != nil {
+		return gitDir
+	}
+	common := strings.TrimSpace(string(data))
+	if !filepath.IsAbs(common) {
+		common = filepath.Join(gitDir, common)
+	}
+	return common
+}
+
+// excludesFile returns the core.excludesFile git would use for root: the
+// repository, global, XDG and system configs in that order of precedence,
+// falling back to $XDG_CONFIG_HOME/git/ignore.
+func excludesFile(root string) string {
+	home, _ := os.UserHomeDir()
+	xdg := os.Getenv("XDG_CONFIG_HOME")
+	if xdg == "" && home != "" {
+		xdg = filepath.Join(home, ".config")
+	}
+
+	var configs []string
+	if gitDir, err := GitDir(root); err == nil {
+		configs = append(configs, filepath.Join(commonGitDir(gitDir), "config"))
+	}
+	if home != "" {
+		configs = append(configs, filepath.Join(home, ".gitconfig"))
+	}
+	if xdg != "" {
+		configs = append(configs, filepath.Join(xdg, "git", "config"))
+	}
+	configs = append(configs, "/etc/gitconfig")
+
+	for _, path := range configs {
+		f, err := os.Open(path)
+		if err != nil {
+			continue
+		}
+		raw := config.New()
+		err = config.NewDecoder(f).Decode(raw)
+		f.Close()
+		if err != nil {
+			continue
+		}
+		if value := raw.Section("core").Options.Get("excludesfile"); value != "" {
+			if strings.HasPrefix(value, "~/") && home != "" {
+				value = filepath.Join(home, value[2:])
+			}
+			return value
+		}
+	}
+	if xdg != "" {
+		return filepath.Join(xdg, "git", "ignore")
+	}
+	return ""
+}
+
+// readIgnoreRules parses an ignore file whose patterns apply below domain
+func readIgnoreRules(path string, domain []string) []ignoreRule {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil
+	}
+	defer f.Close()
+
+	var rules []ignoreRule
+	scanner := bufio.NewScanner(f)
+	for n := 1; scanner.Scan(); n++ {
+		line := scanner.Text()
+		if strings.HasPrefix(line, "#") || strings.TrimSpace(line) == "" {
+			continue
+		}
+		rules = append(rules, ignoreRule{
+			pattern: gitignore.ParsePattern(line, domain),
+			text:    line,
+			source:  fmt.Sprintf("%s:%d", path, n),
+		})
+	}
+	return rules
+}
+
+// rulesFor returns every rule applying inside dir, lowest precedence first
+func (g *GitIgnorer) rulesFor(dir []string) []ignoreRule {
+	rules := append([]ignoreRule(nil), g.global...)
+	for i := 0; i <= len(dir); i++ {
+		key := strings.Join(dir[:i], "/")
+		own, ok := g.dirs[key]
+		if !ok {
+			own = readIgnoreRules(filepath.Join(g.root, filepath.Join(dir[:i]...), ".gitignore"), dir[:i])
+			g.dirs[key] = own
+		}
+		rules = append(rules, own...)
+	}
+	return rules
+}
+
+// matchRules applies the rules of the parent directory to path
+func (g *GitIgnorer) matchRules(segs []string, isDir bool) (bool, string) {
+	rules := g.rulesFor(segs[:len(segs)-1])
+	for i := len(rules) - 1; i >= 0; i-- {
+		switch rules[i].pattern.Match(segs, isDir) {
+		case gitignore.Exclude:
+			return true, fmt.Sprintf("ignored by %q (%s)", rules[i].text, rules[i].source)
+		case gitignore.Include:
+			return false, ""
+		}
+	}
+	return false, ""
+}
+
+// Match reports whether git ignores path, with the rule responsible. As in
+// git, nothing below an ignored directory can be re-included.
+func (g *GitIgnorer) Match(path string, isDir bool) (bool, string) {
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return false, ""
+	}
+	rel, err := filepath.Rel(g.root, abs)
+	if err != nil || rel == "." || strings.HasPrefix(rel, "..") {
+		return false, ""
+	}
+	segs := strings.Split(filepath.ToSlash(rel), "/")
+
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	for i := 1; i < len(segs); i++ {
+		key := strings.Join(segs[:i], "/")
+		verdict, ok := g.seen[key]
+		if !ok {
+			verdict.ignored, verdict.reason = g.matchRules(segs[:i], true)
+			g.seen[key] = verdict
+		}
+		if verdict.ignored {
+			return true, verdict.reason
+		}
+	}
+	return g.matchRules(segs, isDir)
+}
@@ -0,0 +1,74 @@
+package core
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestGitIgnorer(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	t.Setenv("XDG_CONFIG_HOME", filepath.Join(home, ".config"))
+	os.MkdirAll(filepath.Join(home, ".config", "git"), 0755)
+	os.WriteFile(filepath.Join(home, ".config", "git", "ignore"), []byte("*.swp\n"), 0644)
+
+	root := t.TempDir()
+	write := func(rel string, content string) {
+		path := filepath.Join(root, filepath.FromSlash(rel))
+		os.MkdirAll(filepath.Dir(path), 0755)
+		os.WriteFile(path, []byte(content), 0644)
+	}
+	write(".git/info/exclude", "local.env\n")
+	write(".gitignore", "*.log\nbuild/\n")
+	write("sub/.gitignore", "!keep.log\n/data\n")
+
+	g := NewGitIgnorer(filepath.Join(root, "sub"))
+	tests := []struct {
+		path  string
+		isDir bool
+		want  bool
+	}{
+		{"app.log", false, true},
+		{"sub/keep.log", false, false},
+		{"sub/other.log", false, true},
+		{"sub/data", true, true},
+		{"data", true, false},
+		{"build/keep.log", false, true},
+		{"x/build", true, true},
+		{"local.env", false, true},
+		{"notes.swp", false, true},
+		{"main.go", false, false},
+	}
+	for _, tt := range tests {
+		if got, _ := g.Match(filepath.Join(root, tt.path), tt.isDir); got != tt.want {
+			t.Errorf("Match(%q) = %v, want %v", tt.path, got, tt.want)
+		}
+	}
+	if g.Root() != root {
+		t.Errorf("root should be the repository root, got %s", g.Root())
+	}
+}
+
+func TestIterFolder_NestedGitignore(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	root := t.TempDir()
+	os.Mkdir(filepath.Join(root, ".git"), 0755)
+	os.WriteFile(filepath.Join(root, ".gitignore"), []byte("secrets/\n"), 0644)
+	sub := filepath.Join(root, "svc")
+	os.MkdirAll(filepath.Join(sub, "secrets"), 0755)
+	os.WriteFile(filepath.Join(sub, "secrets", "key.txt"), []byte("k8Vq2Lr9Zx4Pw7Mn3Tb6Yc1Hd5Gf0Js\n"), 0644)
+	os.WriteFile(filepath.Join(sub, "app.txt"), []byte("k8Vq2Lr9Zx4Pw7Mn3Tb6Yc1Hd5Gf0Js\n"), 0644)
+
+	result := &ScanResult{}
+	result.Init()
+	if err := result.IterFolder(sub, EntropyFilter(3.0), true, 1<<20); err != nil {
+		t.Fatalf("IterFolder failed: %v", err)
+	}
+	if _, ok := result.filenameMap[filepath.Join(sub, "secrets", "key.txt")]; ok {
+		t.Error("the root .gitignore should apply when scanning a subdirectory")
+	}
+	if _, ok := result.filenameMap[filepath.Join(sub, "app.txt")]; !ok {
+		t.Error("files not ignored should be scanned")
+	}
+}
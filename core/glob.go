@@ -0,0 +1,71 @@
+package core
+
+import (
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// ValidGlob reports whether pattern is a well-formed glob for MatchGlob
+func ValidGlob(pattern string) bool {
+	for _, seg := range strings.Split(strings.Trim(filepath.ToSlash(pattern), "/"), "/") {
+		if seg == "**" {
+			continue
+		}
+		if _, err := path.Match(seg, ""); err != nil {
+			return false
+		}
+	}
+	return true
+}
+
+// MatchGlob reports whether a slash separated relative path matches a glob
+// with gitignore-like semantics: `**` matches any number of directories, a
+// pattern without a slash matches at any depth, a leading slash anchors the
+// pattern to the root and a pattern matching a directory matches everything
+// below it.
+func MatchGlob(pattern string, name string) bool {
+	pattern = filepath.ToSlash(pattern)
+	name = strings.Trim(filepath.ToSlash(name), "/")
+	if strings.HasPrefix(pattern, "/") {
+		pattern = strings.TrimPrefix(pattern, "/")
+	} else if !strings.Contains(strings.TrimSuffix(pattern, "/"), "/") {
+		pattern = "**/" + pattern
+	}
+	pattern = strings.TrimSuffix(pattern, "/")
+	if pattern == "" {
+		return false
+	}
+
+	pat := strings.Split(pattern, "/")
+	segs := strings.Split(name, "/")
+	// A match on any leading directory covers the whole subtree
+	for i := len(segs); i > 0; i-- {
+		if matchSegments(pat, segs[:i]) {
+			return true
+		}
+	}
+	return false
+}
+
+// matchSegments matches glob segments against path segments
+func matchSegments(pat []string, segs []string) bool {
+	for len(pat) > 0 {
+		if pat[0] == "**" {
+			for i := 0; i <= len(segs); i++ {
+				if matchSegments(pat[1:], segs[i:]) {
+					return true
+				}
+			}
+			return false
+		}
+		if len(segs) == 0 {
+			return false
+		}
+		if ok, _ := path.Match(pat[0], segs[0]); !ok {
+			return false
+		}
+		pat, segs = pat[1:], segs[1:]
+	}
+	return len(segs) == 0
+}
@@ -0,0 +1,33 @@
+package core
+
+import "testing"
+
+func TestMatchGlob(t *testing.T) {
+	tests := []struct {
+		pattern string
+		path    string
+		want    bool
+	}{
+		{"testdata/", "testdata/keys.txt", true},
+		{"testdata/", "pkg/testdata/keys.txt", true},
+		{"testdata", "pkg/testdata/a/b.json", true},
+		{"/testdata/", "pkg/testdata/keys.txt", false},
+		{"deploy/**", "deploy/prod/values.yaml", true},
+		{"deploy/**", "src/deploy/values.yaml", false},
+		{"**/fixtures/*.json", "a/b/fixtures/user.json", true},
+		{"**/fixtures/*.json", "fixtures/user.json", true},
+		{"*.pem", "certs/server.pem", true},
+		{"*.pem", "certs/server.pem.txt", false},
+		{"src/**/secret.go", "src/secret.go", true},
+		{"src/*.go", "src/a/b.go", false},
+		{"test", "latest/file.go", false},
+	}
+	for _, tt := range tests {
+		if got := MatchGlob(tt.pattern, tt.path); got != tt.want {
+			t.Errorf("MatchGlob(%q, %q) = %v, want %v", tt.pattern, tt.path, got, tt.want)
+		}
+	}
+	if ValidGlob("src/[a") {
+		t.Error("unterminated class should be invalid")
+	}
+}
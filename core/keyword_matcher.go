@@ -0,0 +1,99 @@
+package core
+
+// keywordMatcher is an Aho-Corasick automaton finding, in one pass over a
+// text, which of a set of keywords occur in it. ASCII letters match in either
+// case.
+type keywordMatcher struct {
+	// class maps each byte to its column in delta, 0 for bytes that occur
+	// in no keyword
+	class [256]uint8
+	width int
+	// delta is the transition table, width entries per state, with failure
+	// links already folded in so matching never backtracks
+	delta []int32
+	// out lists the keywords ending at each state, including through its
+	// failure links
+	out [][]int
+}
+
+// lowerASCII folds the ASCII letters of b to lower case
+func lowerASCII(b byte) byte {
+	if 'A' <= b && b <= 'Z' {
+		return b + 'a' - 'A'
+	}
+	return b
+}
+
+// newKeywordMatcher builds the automaton of keywords, which must not be empty
+func newKeywordMatcher(keywords []string) *keywordMatcher {
+	m := &keywordMatcher{}
+	classes := 0
+	for _, k := range keywords {
+		for i := 0; i < len(k); i++ {
+			c := lowerASCII(k[i])
+			if m.class[c] == 0 {
+				classes++
+				m.class[c] = uint8(classes)
+			}
+		}
+	}
+	for c := 'A'; c <= 'Z'; c++ {
+		m.class[c] = m.class[c+'a'-'A']
+	}
+	m.width = classes + 1
+
+	// Trie of the keywords, 0 marking a missing edge since no edge leads back to the root
+	m.delta = make([]int32, m.width)
+	m.out = [][]int{nil}
+	for k, word := range keywords {
+		state := 0
+		for i := 0; i < len(word); i++ {
+			edge := state*m.width + int(m.class[word[i]])
+			if m.delta[edge] == 0 {
+				m.delta[edge] = int32(len(m.out))
+				m.delta = append(m.delta, make([]int32, m.width)...)
+				m.out = append(m.out, nil)
+			}
+			state = int(m.delta[edge])
+		}
+		m.out[state] = append(m.out[state], k)
+	}
+
+	// Breadth first, so the failure state of every state is complete before it
+	fail := make([]int32, len(m.out))
+	var queue []int32
+	for c := 1; c < m.width; c++ {
+		if child := m.delta[c]; child != 0 {
+			queue = append(queue, child)
+		}
+	}
+	for len(queue) > 0 {
+		s := queue[0]
+		queue = queue[1:]
+		row := int(s) * m.width
+		failRow := int(fail[s]) * m.width
+		for c := 1; c < m.width; c++ {
+			child := m.delta[row+c]
+			if child == 0 {
+				m.delta[row+c] = m.delta[failRow+c]
+				continue
+			}
+			fail[child] = m.delta[failRow+c]
+			m.out[child] = append(m.out[child], m.out[fail[child]]...)
+			queue = append(queue, child)
+		}
+	}
+	return m
+}
+
+// scan calls found with the index of every keyword occurring in s, once per
+// occurrence
+func (m *keywordMatcher) scan(s string, found func(keyword int)) {
+	state := int32(0)
+	for i := 0; i < len(s); i++ {
+		state = m.delta[int(state)*m.width+int(m.class[s[i]])]
+		for _, k := range m.out[state] {
+			found(k)
+		}
+	}
+}
@@ -0,0 +1,172 @@
+package core
+
+import (
+	"fmt"
+	"math/rand"
+	"regexp"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestKeywordMatcher(t *testing.T) {
+	m := newKeywordMatcher([]string{"he", "she", "his", "hers", "Token"})
+	tests := []struct {
+		text string
+		want []int
+	}{
+		{"ushers", []int{0, 1, 3}},
+		{"HIS", []int{2}},
+		{"api_TOKEN=abc", []int{4}},
+		{"nothing to see", nil},
+		{"", nil},
+	}
+	for _, tt := range tests {
+		var got []int
+		m.scan(tt.text, func(k int) {
+			if !slices.Contains(got, k) {
+				got = append(got, k)
+			}
+		})
+		slices.Sort(got)
+		if !slices.Equal(got, tt.want) {
+			t.Errorf("scan(%q) = %v, want %v", tt.text, got, tt.want)
+		}
+	}
+}
+
+func TestRuleSet(t *testing.T) {
+	rules := []Rule{
+		{ID: "acme", Regex: regexp.MustCompile(`ac_[a-z0-9]{16}`), Keywords: []string{"ACME"}},
+		{ID: "aws", Regex: regexp.MustCompile(`AKIA[0-9A-Z]{16}`)},
+		{ID: "hex", Regex: regexp.MustCompile(`[0-9a-f]{32}`)},
+		{ID: "nothing", Regex: nil},
+	}
+	if got := rules[1].keywords(); !slices.Equal(got, []string{"AKIA"}) {
+		t.Errorf("expected the literal prefix as keyword, got %v", got)
+	}
+	if got := rules[2].keywords(); got != nil {
+		t.Errorf("expected no keywords without a literal prefix, got %v", got)
+	}
+
+	filter := TextFilter(RuleSet(rules))
+	tests := []struct {
+		text string
+		want Payload
+	}{
+		{"acme_key=ac_0123456789abcdef", Payload{"acme": "ac_0123456789abcdef"}},
+		{"key=ac_0123456789abcdef", nil},
+		{"AKIAZ7VQ2LR9ZX4PW7MN:0123456789abcdef0123456789abcdef", Payload{"aws": "AKIAZ7VQ2LR9ZX4PW7MN", "hex": "0123456789abcdef0123456789abcdef"}},
+		{"akiaz7vq2lr9zx4pw7mn", nil},
+	}
+	for _, tt := range tests {
+		got, ok := filter(tt.text)
+		if ok != (tt.want != nil) || len(got) != len(tt.want) {
+			t.Errorf("RuleSet(%q) = %v, want %v", tt.text, got, tt.want)
+			continue
+		}
+		for k, v := range tt.want {
+			if got[k] != v {
+				t.Errorf("RuleSet(%q)[%s] = %q, want %q", tt.text, k, got[k], v)
+			}
+		}
+	}
+
+	if RuleSet([]Rule{{ID: "nothing"}}) != nil {
+		t.Error("a rule set without regexes should be nil")
+	}
+}
+
+// syntheticTree returns the files of a generated repository: code lines
+// mentioning many identifiers, with a few secrets of the catalogue rules
+func syntheticTree(files int, lines int) [][]byte {
+	rng := rand.New(rand.NewSource(1))
+	const alphabet = "ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz0123456789"
+	word := func(n int) string {
+		b := make([]byte, n)
+		for i := range b {
+			b[i] = alphabet[rng.Intn(len(alphabet))]
+		}
+		return string(b)
+	}
+	tree := make([][]byte, files)
+	for f := range tree {
+		var b strings.Builder
+		for l := 0; l < lines; l++ {
+			switch rng.Intn(200) {
+			case 0:
+				fmt.Fprintf(&b, "    token = \"svc%03d_%s\"\n", rng.Intn(200), word(32))
+			case 1:
+				fmt.Fprintf(&b, "    aws_key = \"AKIA%s\"\n", strings.ToUpper(word(16)))
+			default:
+				fmt.Fprintf(&b, "    result_%d = self.compute(\"field_%s\", %d, options=None)\n", l, word(8), rng.Intn(1000))
+			}
+		}
+		tree[f] = []byte(b.String())
+	}
+	return tree
+}
+
+// ruleCatalogue returns n rules, each gated by its literal prefix
+func ruleCatalogue(n int) []Rule {
+	rules := []Rule{{ID: "aws", Regex: regexp.MustCompile(`AKIA[0-9A-Z]{16}`), Severity: SeverityHigh}}
+	for i := 1; i < n; i++ {
+		rules = append(rules, Rule{
+			ID:       fmt.Sprintf("svc%03d", i),
+			Regex:    regexp.MustCompile(fmt.Sprintf(`svc%03d_[A-Za-z0-9]{32}`, i)),
+			Severity: SeverityHigh,
+		})
+	}
+	return rules
+}
+
+// BenchmarkRuleSet scans a synthetic tree with a catalogue of 200 rules,
+// once behind the keyword prefilter and once running every regex on every
+// token
+func BenchmarkRuleSet(b *testing.B) {
+	tree := syntheticTree(200, 500)
+	size := 0
+	for _, file := range tree {
+		size += len(file)
+	}
+	rules := ruleCatalogue(200)
+
+	everyRegex := make([]LineFilter, len(rules))
+	for i := range rules {
+		r := rules[i]
+		everyRegex[i] = func(s string) (Payload, bool) {
+			if secret, ok := r.find(Candidate{Text: s}); ok {
+				return Payload{r.ID: secret}, true
+			}
+			return nil, false
+		}
+	}
+	filters := []struct {
+		name   string
+		filter Filter
+	}{
+		{"prefilter", RuleSet(rules)},
+		{"every-regex", AnyFilters(everyRegex...)},
+	}
+
+	for _, f := range filters {
+		b.Run(f.name, func(b *testing.B) {
+			b.SetBytes(int64(size))
+			for b.Loop() {
+				found := 0
+				for _, file := range tree {
+					lines, err := scanLines(strings.NewReader(string(file)), f.filter, EncodingUTF8, 0)
+					if err != nil {
+						b.Fatal(err)
+					}
+					if lines != nil {
+						found += len(lines.Lines)
+					}
+				}
+				if found == 0 {
+					b.Fatal("expected findings in the synthetic tree")
+				}
+			}
+		})
+	}
+}
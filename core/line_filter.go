@@ -1,12 +1,19 @@
 package core
 
 import (
+	"encoding/base64"
+	"encoding/hex"
 	"log"
 	"math"
+	"net/url"
 	"regexp"
 	"runtime"
+	"sort"
 	"strconv"
+	"strings"
 	"sync"
+	"unicode"
+	"unicode/utf8"
 )
 
 type Payload map[string]string
@@ -67,10 +74,13 @@ func BasicFilter() LineFilter {
 }
 
 
+// AddTargetRegexPattern reports the match of pattern under header.
+// It returns nil for an invalid pattern, which filter compositions skip.
 func AddTargetRegexPattern(header string, pattern string) LineFilter {
 	re, err := regexp.Compile(pattern)
 	if err != nil {
 		log.Printf("Regex can't be loaded, skip this %s", header)
+		return nil
 	}
 
 	return func(s string) (Payload, bool) {
@@ -86,6 +96,142 @@ func AddTargetRegexPattern(header string, pattern string) LineFilter {
 }
 
 
+// encodedRe finds base64, base64url and hex runs long enough to hide a secret
+var encodedRe = regexp.MustCompile(`[A-Za-z0-9+/_-]{16,}={0,2}`)
+
+// urlEscapeRe finds percent-encoded bytes
+var urlEscapeRe = regexp.MustCompile(`%[0-9A-Fa-f]{2}`)
+
+// decoding is a candidate decoded from part of a token, and how
+type decoding struct {
+	scheme string
+	text   string
+}
+
+// isDecodedText reports whether decoded bytes look like text worth rescanning
+func isDecodedText(b []byte) bool {
+	if len(b) == 0 || !utf8.Valid(b) {
+		return false
+	}
+	printable := 0
+	for _, r := range string(b) {
+		if unicode.IsPrint(r) || r == '\n' || r == '\t' || r == '\r' {
+			printable++
+		}
+	}
+	return printable*10 >= utf8.RuneCount(b)*9
+}
+
+// decodeCandidates returns every text decoding of the encoded runs of s
+func decodeCandidates(s string) []decoding {
+	var out []decoding
+	if urlEscapeRe.MatchString(s) {
+		if text, err := url.QueryUnescape(s); err == nil && text != s && isDecodedText([]byte(text)) {
+			out = append(out, decoding{"url", text})
+		}
+	}
+	for _, run := range encodedRe.FindAllString(s, -1) {
+		if len(run)%2 == 0 && strings.Trim(run, "0123456789abcdefABCDEF") == "" {
+			if b, err := hex.DecodeString(run); err == nil && isDecodedText(b) {
+				out = append(out, decoding{"hex", string(b)})
+				continue
+			}
+		}
+		for _, enc := range []struct {
+			scheme string
+			enc    *base64.Encoding
+		}{
+			{"base64", base64.StdEncoding},
+			{"base64", base64.RawStdEncoding},
+			{"base64url", base64.URLEncoding},
+			{"base64url", base64.RawURLEncoding},
+		} {
+			if b, err := enc.enc.DecodeString(run); err == nil && isDecodedText(b) {
+				out = append(out, decoding{enc.scheme, string(b)})
+				break
+			}
+		}
+	}
+	return out
+}
+
+// payloadDetails are payload keys describing a match rather than naming the
+// rule that made it
+var payloadDetails = map[string]bool{
+	"complexity": true, "uri_host": true, "uri_user": true,
+	"decode_chain": true, "decoded": true, "key_path": true, "offset": true, "encoding": true,
+	"placeholder": true, "confidence": true, "severity": true,
+	"cell_type": true, "output_type": true,
+}
+
+// IsReservedPayloadKey reports whether a rule named key would overwrite a
+// payload key the scan sets itself, such as entropy or key_path
+func IsReservedPayloadKey(key string) bool {
+	return key == "entropy" || key == URIPayloadKey || payloadDetails[key]
+}
+
+// matchedRules returns the sorted IDs of the rules behind a payload, naming
+// the uri rule uri and the entropy threshold only when no rule matched
+func matchedRules(pl Payload) []string {
+	var rules []string
+	for k := range pl {
+		switch {
+		case k == URIPayloadKey:
+			rules = append(rules, "uri")
+		case k != "entropy" && !payloadDetails[k]:
+			rules = append(rules, k)
+		}
+	}
+	if _, ok := pl["entropy"]; ok && len(rules) == 0 {
+		rules = append(rules, "entropy")
+	}
+	sort.Strings(rules)
+	return rules
+}
+
+// decodeScan decodes the encoded runs of s and rescans the tokens of the
+// result with inner, descending at most depth layers of encoding
+func decodeScan(s string, inner LineFilter, depth int, chain []string) (Payload, bool) {
+	if depth <= 0 || len(s) < 16 {
+		return nil, false
+	}
+	for _, d := range decodeCandidates(s) {
+		steps := append(chain[:len(chain):len(chain)], d.scheme)
+		for _, token := range strings.Fields(d.text) {
+			if pl, ok := inner(token); ok && pl != nil {
+				merged := Payload{
+					"decode_chain": strings.Join(steps, " -> ") + " -> " + strings.Join(matchedRules(pl), ","),
+				}
+				// The decoded token of a uri match holds its password
+				if _, ok := pl[URIPayloadKey]; !ok {
+					merged["decoded"] = token
+				}
+				for k, v := range pl {
+					merged[k] = v
+				}
+				return merged, true
+			}
+			if pl, ok := decodeScan(token, inner, depth-1, steps); ok {
+				return pl, true
+			}
+		}
+	}
+	return nil, false
+}
+
+// DecodeFilter matches tokens hiding a match of inner behind base64, hex or
+// URL encoding, up to depth layers deep. The payload records the decoded
+// token, except for uri matches, and a decode_chain of the rules that
+// matched such as "base64 -> aws_secret_key".
+func DecodeFilter(inner LineFilter, depth int) LineFilter {
+	if inner == nil || depth <= 0 {
+		return nil
+	}
+	return func(s string) (Payload, bool) {
+		return decodeScan(s, inner, depth, nil)
+	}
+}
+
 // AnyFilters returns a filter that passes if any filter matches
 func AnyFilters(filters ...LineFilter) LineFilter {
 	return func(s string) (Payload, bool) {
@@ -0,0 +1,242 @@
+package core
+
+import (
+	"bufio"
+	"errors"
+	"io"
+	"log"
+	"sort"
+	"strings"
+)
+
+// Lines are read in chunks of lineChunk bytes, so a line of any length is
+// scanned without being held in memory. A token longer than maxTokenLen is
+// scanned in windows of maxTokenLen bytes overlapping by tokenOverlap, so a
+// secret shorter than the overlap is always seen whole by some window.
+const (
+	lineChunk    = 64 << 10
+	maxTokenLen  = 8 << 10
+	tokenOverlap = 1 << 10
+)
+
+// errTruncated reports content cut off before its end
+var errTruncated = errors.New("content exceeds the size limit and was cut off")
+
+// isSpace reports whether b separates tokens
+func isSpace(b byte) bool {
+	return b == ' ' || b == '\t' || b == '\r' || b == '\v' || b == '\f'
+}
+
+// lineStream tokenizes chunks of lines, running filter over each token
+type lineStream struct {
+	filter  Filter
+	enc     TextEncoding // encoding of the original file, positions count its bytes
+	result  CodeLine
+	line    int
+	pos     int             // byte offset in the line of the next chunk byte
+	tok     []byte          // token being read, possibly spanning chunks
+	tokCol  int             // byte offset in the line of tok[0]
+	tokSeen map[string]bool // matches already reported by windows of tok
+	source  string          // the current line when it fits in one chunk, else ""
+}
+
+// check runs the filter over a token starting at byte offset col of the line
+func (s *lineStream) check(token []byte, col int) {
+	text := string(token)
+	if pl, ok := s.filter.Check(Candidate{Text: text, Line: s.line, Column: col + 1, Source: s.source}); ok && pl != nil {
+		s.add(text, col, pl)
+	}
+}
+
+// checkWindow runs the filter over a window of an over-long token starting at
+// byte offset col of the line. Each distinct match of the token is reported
+// once, at the text the rules extracted when the window contains it.
+func (s *lineStream) checkWindow(window []byte, col int) {
+	text := string(window)
+	pl, ok := s.filter.Check(Candidate{Text: text, Line: s.line, Column: col + 1, Source: s.source})
+	if !ok || pl == nil {
+		return
+	}
+	rules := matchedRules(pl)
+	key, match := strings.Join(rules, ","), text
+	for _, rule := range rules {
+		if rule == "uri" {
+			rule = URIPayloadKey
+		}
+		v := pl[rule]
+		if rule == "entropy" || v == "" {
+			continue
+		}
+		key += "\x00" + v
+		if i := strings.Index(text, v); i >= 0 && match == text {
+			match, col = v, col+s.enc.originalLen(window[:i])
+		}
+	}
+	if s.tokSeen[key] {
+		return
+	}
+	s.tokSeen[key] = true
+	s.add(match, col, pl)
+}
+
+// add records a finding of the current line at byte offset col
+func (s *lineStream) add(text string, col int, pl Payload) {
+	if s.enc != EncodingUTF8 {
+		pl["encoding"] = string(s.enc)
+	}
+	s.result.Lines = append(s.result.Lines, text)
+	s.result.Indexes = append(s.result.Indexes, s.line)
+	s.result.Columns = append(s.result.Columns, col+1)
+	s.result.Extracted = append(s.result.Extracted, pl)
+}
+
+// feed consumes part of the current line
+func (s *lineStream) feed(chunk []byte) {
+	for i := 0; i < len(chunk); {
+		if isSpace(chunk[i]) {
+			s.endToken()
+			s.pos += s.enc.originalLen(chunk[i : i+1])
+			i++
+			continue
+		}
+		j := i
+		for j < len(chunk) && !isSpace(chunk[j]) {
+			j++
+		}
+		if len(s.tok) == 0 {
+			s.tokCol = s.pos
+		}
+		s.tok = append(s.tok, chunk[i:j]...)
+		s.pos += s.enc.originalLen(chunk[i:j])
+		i = j
+		for len(s.tok) > maxTokenLen {
+			s.window()
+		}
+	}
+}
+
+// window scans the first maxTokenLen bytes of an over-long token and slides
+// past them, keeping tokenOverlap bytes
+func (s *lineStream) window() {
+	if s.tokSeen == nil {
+		s.tokSeen = make(map[string]bool)
+	}
+	s.checkWindow(s.tok[:maxTokenLen], s.tokCol)
+	step := maxTokenLen - tokenOverlap
+	s.tokCol += s.enc.originalLen(s.tok[:step])
+	n := copy(s.tok, s.tok[step:])
+	s.tok = s.tok[:n]
+}
+
+// endToken scans the token read so far
+func (s *lineStream) endToken() {
+	switch {
+	case len(s.tok) == 0:
+	case s.tokSeen != nil:
+		s.checkWindow(s.tok, s.tokCol)
+	default:
+		s.check(s.tok, s.tokCol)
+	}
+	s.tok = s.tok[:0]
+	s.tokSeen = nil
+}
+
+// endLine finishes the current line
+func (s *lineStream) endLine() {
+	s.endToken()
+	s.line++
+	s.pos = 0
+	s.source = ""
+}
+
+// scanLines runs filter over every whitespace separated token of r, UTF-8
+// text transcoded from enc with a BOM of bom bytes dropped. Columns are
+// 1-based byte columns of the original file. Findings made before a read
+// error are kept and returned along with it.
+func scanLines(r io.Reader, filter Filter, enc TextEncoding, bom int) (*CodeLine, error) {
+	br := bufio.NewReaderSize(r, lineChunk)
+	s := &lineStream{filter: filter, enc: enc, line: 1, pos: bom}
+	var err error
+	for {
+		var chunk []byte
+		chunk, err = br.ReadSlice('\n')
+		start := s.pos == 0 || (s.line == 1 && s.pos == bom)
+		if n := len(chunk); n > 0 && chunk[n-1] == '\n' {
+			if start {
+				s.source = string(chunk[:n-1])
+			}
+			s.feed(chunk[:n-1])
+			s.endLine()
+			continue
+		}
+		if start && err != bufio.ErrBufferFull {
+			s.source = string(chunk)
+		}
+		s.feed(chunk)
+		if err == bufio.ErrBufferFull {
+			continue
+		}
+		s.endToken()
+		break
+	}
+	if err == io.EOF {
+		err = nil
+	}
+
+	if len(s.result.Lines) == 0 {
+		return nil, err
+	}
+	return &s.result, err
+}
+
+// limitReader reads at most n bytes of r, failing with errTruncated from
+// then on when r holds more
+type limitReader struct {
+	r   io.Reader
+	n   int64
+	err error
+}
+
+func (l *limitReader) Read(p []byte) (int, error) {
+	if l.err != nil {
+		return 0, l.err
+	}
+	if l.n <= 0 {
+		var one [1]byte
+		if _, err := io.ReadFull(l.r, one[:]); err == nil {
+			l.err = errTruncated
+		} else {
+			l.err = io.EOF
+		}
+		return 0, l.err
+	}
+	if int64(len(p)) > l.n {
+		p = p[:l.n]
+	}
+	n, err := l.r.Read(p)
+	l.n -= int64(n)
+	return n, err
+}
+
+// markTruncated records that a file was only partly scanned
+func (res *ScanResult) markTruncated(path string, err error) {
+	log.Printf("[core.linescan] %s was only partly scanned: %v", path, err)
+	res.mutex.Lock()
+	defer res.mutex.Unlock()
+	if res.truncated == nil {
+		res.truncated = make(map[string]string)
+	}
+	res.truncated[path] = err.Error()
+}
+
+// Truncated lists the files that were only partly scanned, with the reason
+func (res *ScanResult) Truncated() []string {
+	res.mutex.RLock()
+	defer res.mutex.RUnlock()
+	out := make([]string, 0, len(res.truncated))
+	for path, reason := range res.truncated {
+		out = append(out, path+": "+reason)
+	}
+	sort.Strings(out)
+	return out
+}
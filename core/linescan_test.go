@@ -0,0 +1,92 @@
+package core
+
+import (
+	"bytes"
+	"compress/gzip"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestScanLines_LongLines(t *testing.T) {
+	const secret = "SECRET12345678"
+	filter := AddTargetRegexPattern("key", `SECRET[0-9]{8}`)
+	padding := strings.Repeat("ab ", 100000)
+	blob := strings.Repeat("x", maxTokenLen-5)
+
+	tests := []struct {
+		name    string
+		content string
+		line    int
+		col     int
+	}{
+		{"short line", "token " + secret + "\n", 1, 7},
+		{"after a 300KB line", padding + "\n" + padding + secret + "\n", 2, len(padding) + 1},
+		{"end of a line without newline", "first\n" + padding + secret, 2, len(padding) + 1},
+		{"across a window boundary", blob + secret + strings.Repeat("y", 3*maxTokenLen), 1, 1 + len(blob)},
+		{"deep inside a huge token", strings.Repeat("z", 50*maxTokenLen) + secret + "z\nnext", 1, 1 + 50*maxTokenLen},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			lines, err := scanLines(strings.NewReader(tt.content), filter, EncodingUTF8, 0)
+			if err != nil {
+				t.Fatalf("scanLines failed: %v", err)
+			}
+			if lines == nil || len(lines.Lines) != 1 {
+				t.Fatalf("expected exactly one finding, got %+v", lines)
+			}
+			if lines.Lines[0] != secret || lines.Extracted[0]["key"] != secret || lines.Indexes[0] != tt.line || lines.Columns[0] != tt.col {
+				t.Errorf("got %q at %d:%d, want line %d col %d",
+					lines.Extracted[0]["key"], lines.Indexes[0], lines.Columns[0], tt.line, tt.col)
+			}
+		})
+	}
+}
+
+func TestScanLines_HugeTokenMatches(t *testing.T) {
+	filter := AddTargetRegexPattern("key", `SECRET[0-9]{8}`)
+	first, second := "SECRET11111111", "SECRET22222222"
+	filler := strings.Repeat("z", 20*maxTokenLen)
+	content := filler + first + filler + second + filler + first
+
+	lines, err := scanLines(strings.NewReader(content), filter, EncodingUTF8, 0)
+	if err != nil {
+		t.Fatalf("scanLines failed: %v", err)
+	}
+	if lines == nil || len(lines.Lines) != 2 {
+		t.Fatalf("expected one finding per distinct secret, got %v", lines)
+	}
+	want := []struct {
+		text string
+		col  int
+	}{{first, 1 + len(filler)}, {second, 1 + 2*len(filler) + len(first)}}
+	for i, w := range want {
+		if lines.Lines[i] != w.text || lines.Extracted[i]["key"] != w.text || lines.Columns[i] != w.col {
+			t.Errorf("finding %d = %q at col %d, want %q at col %d", i, lines.Lines[i], lines.Columns[i], w.text, w.col)
+		}
+	}
+}
+
+func TestScanArchive_TruncatedEntry(t *testing.T) {
+	dir := t.TempDir()
+	var buf bytes.Buffer
+	gz := gzip.NewWriter(&buf)
+	gz.Write([]byte("key=" + archiveSecret + "\n" + strings.Repeat("filler ", 1000)))
+	gz.Close()
+	path := filepath.Join(dir, "dump.txt.gz")
+	os.WriteFile(path, buf.Bytes(), 0644)
+
+	var res ScanResult
+	res.Init()
+	if err := res.ScanArchive(path, EntropyFilter(4.0), 1024); err != nil {
+		t.Fatalf("ScanArchive failed: %v", err)
+	}
+	if _, ok := res.filenameMap[path+ArchiveEntrySep+"dump.txt"]; !ok {
+		t.Error("expected the findings before the cut to be kept")
+	}
+	truncated := res.Truncated()
+	if len(truncated) != 1 || !strings.HasPrefix(truncated[0], path+ArchiveEntrySep+"dump.txt: ") {
+		t.Errorf("expected the entry to be reported as truncated, got %v", truncated)
+	}
+}
@@ -0,0 +1,14 @@
+//go:build !unix
+
+package core
+
+import "os"
+
+// lockFile creates path but takes no lock, flock is only available on unix
+func lockFile(path string) (unlock func(), err error) {
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_RDWR, 0600)
+	if err != nil {
+		return nil, err
+	}
+	return func() { f.Close() }, nil
+}
@@ -0,0 +1,220 @@
+package core
+
+import (
+	"encoding/json"
+	"fmt"
+	"maps"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strings"
+)
+
+// NotebookCellSep separates a notebook from the cell inside it in virtual
+// paths like analysis.ipynb#cell-7, cells counting from 1
+const NotebookCellSep = "#cell-"
+
+// notebookLanguages maps kernel languages to the extension of their grammar
+var notebookLanguages = map[string]string{
+	"python": ".py", "r": ".r", "julia": ".jl", "javascript": ".js",
+	"typescript": ".ts", "scala": ".scala", "ruby": ".rb", "bash": ".sh",
+	"go": ".go", "rust": ".rs", "java": ".java", "c++": ".cpp", "sql": ".sql",
+}
+
+// ansiEscapeRe matches the terminal colour codes of error tracebacks
+var ansiEscapeRe = regexp.MustCompile(`\x1b\[[0-9;]*[A-Za-z]`)
+
+// notebookMetadataRe matches key paths of notebook and cell metadata
+var notebookMetadataRe = regexp.MustCompile(`^(cells\[\d+\]\.)?metadata\.`)
+
+// notebookText is notebook text, stored either as a string or a list of lines
+type notebookText string
+
+func (t *notebookText) UnmarshalJSON(data []byte) error {
+	var lines []string
+	if err := json.Unmarshal(data, &lines); err == nil {
+		*t = notebookText(strings.Join(lines, ""))
+		return nil
+	}
+	var s string
+	if err := json.Unmarshal(data, &s); err != nil {
+		return err
+	}
+	*t = notebookText(s)
+	return nil
+}
+
+// notebook is the part of an nbformat 4 document that is scanned
+type notebook struct {
+	Metadata struct {
+		Kernelspec struct {
+			Language string `json:"language"`
+		} `json:"kernelspec"`
+		LanguageInfo struct {
+			Name string `json:"name"`
+		} `json:"language_info"`
+	} `json:"metadata"`
+	Cells []struct {
+		CellType string           `json:"cell_type"`
+		Source   notebookText     `json:"source"`
+		Outputs  []notebookOutput `json:"outputs"`
+	} `json:"cells"`
+}
+
+// notebookOutput is one output of a code cell
+type notebookOutput struct {
+	OutputType string                     `json:"output_type"`
+	Text       notebookText               `json:"text"`
+	Data       map[string]json.RawMessage `json:"data"`
+	EName      string                     `json:"ename"`
+	EValue     string                     `json:"evalue"`
+	Traceback  []string                   `json:"traceback"`
+}
+
+// IsNotebook reports whether a file is a Jupyter notebook
+func IsNotebook(name string) bool {
+	return strings.EqualFold(filepath.Ext(name), ".ipynb")
+}
+
+// IsNotebookCell reports whether path is a virtual path inside a notebook
+func IsNotebookCell(path string) bool {
+	return strings.Contains(path, NotebookCellSep)
+}
+
+// NotebookFile returns the notebook holding a virtual path
+func NotebookFile(path string) string {
+	if i := strings.Index(path, NotebookCellSep); i >= 0 {
+		return path[:i]
+	}
+	return path
+}
+
+// IsVirtualPath reports whether path names a part of a file rather than a
+// file on disk
+func IsVirtualPath(path string) bool {
+	return IsArchiveEntry(path) || IsNotebookCell(path)
+}
+
+// DiskFile returns the file on disk holding a path, virtual or not
+func DiskFile(path string) string {
+	return ArchiveFile(NotebookFile(path))
+}
+
+// language returns the grammar extension of the notebook's kernel, Python
+// when the notebook does not say
+func (nb *notebook) language() string {
+	lang := nb.Metadata.Kernelspec.Language
+	if lang == "" {
+		lang = nb.Metadata.LanguageInfo.Name
+	}
+	if ext, ok := notebookLanguages[strings.ToLower(lang)]; ok {
+		return ext
+	}
+	return ".py"
+}
+
+// text returns the scannable text of an output and what kind it is. Images
+// and other binary data are left out.
+func (o notebookOutput) text() (string, string) {
+	switch o.OutputType {
+	case "stream":
+		return string(o.Text), o.OutputType
+	case "error":
+		tb := ansiEscapeRe.ReplaceAllString(strings.Join(o.Traceback, "\n"), "")
+		return o.EName + ": " + o.EValue + "\n" + tb, o.OutputType
+	}
+	var parts, kinds []string
+	for _, mime := range slices.Sorted(maps.Keys(o.Data)) {
+		if strings.HasPrefix(mime, "image/") && mime != "image/svg+xml" {
+			continue
+		}
+		var t notebookText
+		if err := json.Unmarshal(o.Data[mime], &t); err == nil {
+			parts = append(parts, string(t))
+		} else if indented, err := json.MarshalIndent(o.Data[mime], "", " "); err == nil {
+			parts = append(parts, string(indented))
+		}
+		kinds = append(kinds, mime)
+	}
+	return strings.Join(parts, "\n"), o.OutputType + " " + strings.Join(kinds, ",")
+}
+
+// scanCell runs filter over a code cell with the grammar of ext, falling
+// back to a line scan when the grammar is unavailable
+func scanCell(source string, ext string, filter Filter) *CodeLine {
+	code := []byte(source)
+	if tree, err := parseSource("cell"+ext, code); err == nil {
+		return walkParse(tree.RootNode(), filter, code)
+	}
+	lines, _ := scanLines(strings.NewReader(source), filter, EncodingUTF8, 0)
+	return lines
+}
+
+// ScanNotebook scans the cells of a Jupyter notebook, code cells with the
+// grammar of the notebook's kernel, along with their outputs and the
+// notebook's metadata. Cell sources are recorded under name#cell-N, their
+// outputs under name#cell-N/output-M with lines counting from the start of
+// the cell or output, and metadata under the notebook itself with its
+// key_path. Parse errors are returned so callers can fall back to a line scan.
+func (res *ScanResult) ScanNotebook(filename string, filter Filter) (map[string]CodeLine, error) {
+	if isNilFilter(filter) {
+		return nil, nil
+	}
+	data, err := os.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+	var nb notebook
+	if err := json.Unmarshal(data, &nb); err != nil {
+		return nil, fmt.Errorf("%s is not a notebook: %w", filename, err)
+	}
+	text := newTextLines(data)
+	values, err := parseJSONValues(data, text)
+	if err != nil {
+		return nil, err
+	}
+
+	found := make(map[string]CodeLine)
+	record := func(path string, lines *CodeLine, key string, value string) {
+		if lines == nil || len(lines.Lines) == 0 {
+			return
+		}
+		for _, pl := range lines.Extracted {
+			if pl != nil {
+				pl[key] = value
+			}
+		}
+		found[path] = *lines
+	}
+
+	ext := nb.language()
+	for i, cell := range nb.Cells {
+		cellPath := fmt.Sprintf("%s%s%d", filename, NotebookCellSep, i+1)
+		var lines *CodeLine
+		if cell.CellType == "code" {
+			lines = scanCell(string(cell.Source), ext, inFile(filter, cellPath, LanguageOf("cell"+ext)))
+		} else {
+			lines, _ = scanLines(strings.NewReader(string(cell.Source)), inFile(filter, cellPath, ""), EncodingUTF8, 0)
+		}
+		record(cellPath, lines, "cell_type", cell.CellType)
+
+		for j, out := range cell.Outputs {
+			body, kind := out.text()
+			outPath := fmt.Sprintf("%s/output-%d", cellPath, j+1)
+			lines, _ := scanLines(strings.NewReader(body), inFile(filter, outPath, ""), EncodingUTF8, 0)
+			record(outPath, lines, "output_type", kind)
+		}
+	}
+
+	var metadata []structuredValue
+	for _, v := range values {
+		if notebookMetadataRe.MatchString(v.path) {
+			metadata = append(metadata, v)
+		}
+	}
+	if meta := scanValues(metadata, text, inFile(filter, filename, LanguageOf(".json")), EncodingUTF8); len(meta.Lines) > 0 {
+		found[filename] = meta
+	}
+	return found, nil
+}
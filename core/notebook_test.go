@@ -0,0 +1,135 @@
+package core
+
+import (
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"testing"
+)
+
+func TestScanNotebook(t *testing.T) {
+	const secret = "k8Vq2Lr9Zx4Pw7Mn3Tb6Yc1Hd5Gf0Js"
+	const image = "iVBORw0KGgoAAAANSUhEUgAAAAEAAAABCAYAAAAfFcSJAAAADUlEQVR42mNkYPhfDwAChwGA60e6kgAAAABJRU5ErkJggg"
+	content := `{
+ "cells": [
+  {
+   "cell_type": "markdown",
+   "metadata": {},
+   "source": ["# Setup\n", "Nothing to see here\n"]
+  },
+  {
+   "cell_type": "code",
+   "execution_count": 1,
+   "metadata": {"tags": ["` + secret + `"]},
+   "outputs": [
+    {"output_type": "stream", "name": "stdout", "text": ["connecting\n", "token ` + secret + `\n"]},
+    {"output_type": "display_data", "metadata": {}, "data": {"image/png": "` + image + `", "text/plain": ["<Figure>"]}},
+    {"output_type": "error", "ename": "ValueError", "evalue": "bad key",
+     "traceback": ["\u001b[0;31mValueError\u001b[0m: bad key ` + secret + `"]}
+   ],
+   "source": ["import os\n", "\n", "key = \"` + secret + `\"\n"]
+  }
+ ],
+ "metadata": {"kernelspec": {"language": "python", "name": "python3"}},
+ "nbformat": 4,
+ "nbformat_minor": 5
+}`
+	file := filepath.Join(t.TempDir(), "analysis.ipynb")
+	os.WriteFile(file, []byte(content), 0644)
+
+	result := &ScanResult{}
+	result.Init()
+	found, err := result.ScanNotebook(file, EntropyFilter(4.0))
+	if err != nil {
+		t.Fatalf("ScanNotebook failed: %v", err)
+	}
+
+	tests := []struct {
+		path string
+		line int
+		key  string
+		want string
+	}{
+		{file + "#cell-2", 3, "cell_type", "code"},
+		{file + "#cell-2/output-1", 2, "output_type", "stream"},
+		{file + "#cell-2/output-3", 2, "output_type", "error"},
+		{file, 11, "key_path", "cells[1].metadata.tags[0]"},
+	}
+	if len(found) != len(tests) {
+		t.Errorf("expected findings in %d places, got %v", len(tests), found)
+	}
+	for _, tt := range tests {
+		lines, ok := found[tt.path]
+		if !ok {
+			t.Errorf("no findings under %s", tt.path)
+			continue
+		}
+		var hit bool
+		for i, text := range lines.Lines {
+			if text == secret || text == `"`+secret+`"` {
+				hit = lines.Indexes[i] == tt.line && lines.Extracted[i][tt.key] == tt.want
+			}
+		}
+		if !hit {
+			t.Errorf("%s: secret not found at line %d with %s=%s, got %+v", tt.path, tt.line, tt.key, tt.want, lines)
+		}
+	}
+}
+
+func TestScanNotebook_RuleIDs(t *testing.T) {
+	const secret = "ak_k8Vq2Lr9Zx4Pw7Mn3"
+	content := `{"cells": [{"cell_type": "code", "metadata": {},
+  "outputs": [{"output_type": "stream", "name": "stdout", "text": ["` + secret + `\n"]}],
+  "source": ["key = \"` + secret + `\"\n"]}],
+ "metadata": {}, "nbformat": 4, "nbformat_minor": 5}`
+	file := filepath.Join(t.TempDir(), "keys.ipynb")
+	os.WriteFile(file, []byte(content), 0644)
+
+	result := &ScanResult{}
+	result.Init()
+	found, err := result.ScanNotebook(file, RuleFilter(Rule{ID: "acme", Regex: regexp.MustCompile(`ak_[A-Za-z0-9]{16}`)}))
+	if err != nil {
+		t.Fatalf("ScanNotebook failed: %v", err)
+	}
+	if len(found) != 2 {
+		t.Fatalf("expected findings in the cell and its output, got %v", found)
+	}
+	for path, lines := range found {
+		for _, pl := range lines.Extracted {
+			if rules := matchedRules(pl); !slices.Equal(rules, []string{"acme"}) {
+				t.Errorf("%s: matched rules = %v, want [acme]", path, rules)
+			}
+		}
+	}
+}
+
+func TestScanNotebook_VirtualPaths(t *testing.T) {
+	path := "dir/analysis.ipynb" + NotebookCellSep + "7/output-1"
+	if !IsNotebookCell(path) || !IsVirtualPath(path) || DiskFile(path) != "dir/analysis.ipynb" {
+		t.Errorf("unexpected handling of %s", path)
+	}
+	if CanAllowInline(path) || SupportsEnvFix(path) {
+		t.Errorf("notebook cells cannot be rewritten in place")
+	}
+	if IsVirtualPath("dir/analysis.ipynb") || DiskFile("lib.jar!/nb.ipynb#cell-1") != "lib.jar" {
+		t.Errorf("unexpected handling of disk files")
+	}
+}
+
+func TestScanNotebook_InvalidFallsBack(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "broken.ipynb")
+	os.WriteFile(file, []byte(`{"cells": [ token = k8Vq2Lr9Zx4Pw7Mn3Tb6Yc1Hd5Gf0Js`), 0644)
+
+	result := &ScanResult{}
+	result.Init()
+	if _, err := result.ScanNotebook(file, EntropyFilter(4.0)); err == nil {
+		t.Fatalf("expected a parse error")
+	}
+	if err := result.IterFiles([]string{file}, EntropyFilter(4.0), 1<<20); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := result.filenameMap[file]; !ok {
+		t.Errorf("expected the line scan fallback to report the secret, got %v", result.filenameMap)
+	}
+}
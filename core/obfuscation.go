@@ -0,0 +1,440 @@
+package core
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+)
+
+// placeholderPrefix marks a secret span that was replaced by obfuscation
+const placeholderPrefix = "GITAEGIS_REDACTED_"
+
+// identRe matches the key of a `key=value` token
+var identRe = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_.-]*$`)
+
+// ObfuscationEntry records a single replaced secret span.
+// Offset is the byte offset of the span in the original file, or in its
+// UTF-8 text for files in another encoding.
+type ObfuscationEntry struct {
+	Line        int    `json:"line"`
+	Offset      int    `json:"offset"`
+	Original    string `json:"original"`
+	Placeholder string `json:"placeholder"`
+}
+
+// ObfuscatedFile records how a file looked before and after obfuscation
+type ObfuscatedFile struct {
+	Path           string             `json:"path"`
+	Mode           os.FileMode        `json:"mode"`
+	OriginalHash   string             `json:"original_hash"`
+	ObfuscatedHash string             `json:"obfuscated_hash"`
+	Encoding       TextEncoding       `json:"encoding,omitempty"` // set for files rewritten as UTF-8 text
+	BOM            int                `json:"bom,omitempty"`
+	Entries        []ObfuscationEntry `json:"entries"`
+}
+
+// revert rebuilds the original content of f from its obfuscated content
+func (f ObfuscatedFile) revert(content []byte) ([]byte, error) {
+	if f.Encoding == "" {
+		return revertEntries(content, f.Entries)
+	}
+	if f.BOM > len(content) {
+		return nil, errors.New("byte order mark is missing")
+	}
+	text, err := decodeAs(content[f.BOM:], f.Encoding)
+	if err != nil {
+		return nil, err
+	}
+	original, err := revertEntries(text, f.Entries)
+	if err != nil {
+		return nil, err
+	}
+	return append(content[:f.BOM:f.BOM], EncodeText(original, f.Encoding)...), nil
+}
+
+// ObfuscationJournal is the restore journal kept outside the worktree
+type ObfuscationJournal struct {
+	Root      string           `json:"root"`
+	Timestamp string           `json:"timestamp"`
+	Files     []ObfuscatedFile `json:"files"`
+}
+
+// JournalDir returns the directory holding restore journals.
+// GITAEGIS_JOURNAL_DIR overrides the default user config location.
+func JournalDir() (string, error) {
+	if dir := os.Getenv("GITAEGIS_JOURNAL_DIR"); dir != "" {
+		return dir, nil
+	}
+	base, err := os.UserConfigDir()
+	if err != nil {
+		return "", fmt.Errorf("unable to locate user config dir: %w", err)
+	}
+	return filepath.Join(base, "gitaegis", "journals"), nil
+}
+
+// JournalPath returns the journal file used for a given worktree root
+func JournalPath(root string) (string, error) {
+	absRoot, err := filepath.Abs(root)
+	if err != nil {
+		return "", err
+	}
+	dir, err := JournalDir()
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256([]byte(absRoot))
+	return filepath.Join(dir, hex.EncodeToString(sum[:8])+".json"), nil
+}
+
+// LoadJournal reads the restore journal for root, returning os.ErrNotExist if none
+func LoadJournal(root string) (*ObfuscationJournal, error) {
+	p, err := JournalPath(root)
+	if err != nil {
+		return nil, err
+	}
+	data, err := os.ReadFile(p)
+	if err != nil {
+		return nil, err
+	}
+	var j ObfuscationJournal
+	if err := json.Unmarshal(data, &j); err != nil {
+		return nil, fmt.Errorf("corrupt obfuscation journal %s: %w", p, err)
+	}
+	return &j, nil
+}
+
+// saveJournal writes the journal atomically with owner-only permissions,
+// removing it entirely once no files are left to restore.
+func saveJournal(root string, j *ObfuscationJournal) error {
+	p, err := JournalPath(root)
+	if err != nil {
+		return err
+	}
+	if len(j.Files) == 0 {
+		if err := os.Remove(p); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+		return nil
+	}
+	if err := os.MkdirAll(filepath.Dir(p), 0700); err != nil {
+		return fmt.Errorf("unable to create journal dir: %w", err)
+	}
+	data, err := json.MarshalIndent(j, "", "  ")
+	if err != nil {
+		return err
+	}
+	tmp := p + ".tmp"
+	if err := os.WriteFile(tmp, data, 0600); err != nil {
+		return err
+	}
+	return os.Rename(tmp, p)
+}
+
+func hashContent(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+// secretSpan narrows a matched token down to the secret itself, dropping
+// surrounding quotes, punctuation and a leading `key=` assignment.
+func secretSpan(token string) string {
+	const trim = "\"'`,;()[]{}"
+	s := strings.Trim(token, trim)
+	if i := strings.Index(s, "="); i > 0 && i < len(s)-1 && s[i+1] != '=' && identRe.MatchString(s[:i]) {
+		s = strings.Trim(s[i+1:], trim)
+	}
+	return s
+}
+
+// placeholderFor returns the replacement text for a secret
+func placeholderFor(secret string) string {
+	sum := sha256.Sum256([]byte(secret))
+	return placeholderPrefix + hex.EncodeToString(sum[:4])
+}
+
+// lineOffsets returns the byte offset at which each line of content starts
+func lineOffsets(content []byte) []int {
+	offsets := []int{0}
+	for i, b := range content {
+		if b == '\n' {
+			offsets = append(offsets, i+1)
+		}
+	}
+	return offsets
+}
+
+// obfuscatePerLine locates every detected secret of a file inside its content
+// and returns the spans to replace, ordered by offset.
+func obfuscatePerLine(content []byte, line CodeLine) ([]ObfuscationEntry, error) {
+	offsets := lineOffsets(content)
+	var entries []ObfuscationEntry
+
+	for i, token := range line.Lines {
+		if i >= len(line.Indexes) {
+			break
+		}
+		idx := line.Indexes[i]
+		if idx < 1 || idx > len(offsets) {
+			return nil, fmt.Errorf("line %d out of range", idx)
+		}
+		start := offsets[idx-1]
+		end := len(content)
+		if idx < len(offsets) {
+			end = offsets[idx] - 1
+		}
+		text := string(content[start:end])
+
+		secret := secretSpan(token)
+		if secret == "" {
+			continue
+		}
+
+		// Prefer the occurrence at or after the reported column
+		pos := -1
+		if i < len(line.Columns) && line.Columns[i] > 0 && line.Columns[i]-1 < len(text) {
+			if p := strings.Index(text[line.Columns[i]-1:], secret); p >= 0 {
+				pos = line.Columns[i] - 1 + p
+			}
+		}
+		if pos < 0 {
+			pos = strings.Index(text, secret)
+		}
+		if pos < 0 {
+			return nil, fmt.Errorf("secret on line %d no longer matches the file", idx)
+		}
+
+		entries = append(entries, ObfuscationEntry{
+			Line:        idx,
+			Offset:      start + pos,
+			Original:    secret,
+			Placeholder: placeholderFor(secret),
+		})
+	}
+
+	sort.Slice(entries, func(a, b int) bool { return entries[a].Offset < entries[b].Offset })
+
+	// Drop overlapping spans, e.g. the same secret reported by two nodes
+	deduped := entries[:0]
+	lastEnd := -1
+	for _, e := range entries {
+		if e.Offset < lastEnd {
+			continue
+		}
+		deduped = append(deduped, e)
+		lastEnd = e.Offset + len(e.Original)
+	}
+	return deduped, nil
+}
+
+// applyEntries rewrites content, replacing each original span by its placeholder
+func applyEntries(content []byte, entries []ObfuscationEntry) []byte {
+	var b strings.Builder
+	b.Grow(len(content))
+	prev := 0
+	for _, e := range entries {
+		b.Write(content[prev:e.Offset])
+		b.WriteString(e.Placeholder)
+		prev = e.Offset + len(e.Original)
+	}
+	b.Write(content[prev:])
+	return []byte(b.String())
+}
+
+// revertEntries rebuilds the original content from an obfuscated one
+func revertEntries(content []byte, entries []ObfuscationEntry) ([]byte, error) {
+	var b strings.Builder
+	b.Grow(len(content))
+	prev, delta := 0, 0
+	for _, e := range entries {
+		at := e.Offset + delta
+		end := at + len(e.Placeholder)
+		if at < prev || end > len(content) || string(content[at:end]) != e.Placeholder {
+			return nil, fmt.Errorf("placeholder for line %d not found", e.Line)
+		}
+		b.Write(content[prev:at])
+		b.WriteString(e.Original)
+		prev = end
+		delta += len(e.Placeholder) - len(e.Original)
+	}
+	b.Write(content[prev:])
+	return []byte(b.String()), nil
+}
+
+// Obfuscate replaces every secret span in blob with a placeholder and records
+// a restore journal outside the worktree. Files still listed in an earlier
+// journal of root are refused until they are restored.
+func Obfuscate(root string, blob map[string]CodeLine) (*ObfuscationJournal, error) {
+	absRoot, err := filepath.Abs(root)
+	if err != nil {
+		return nil, err
+	}
+	journal, err := LoadJournal(absRoot)
+	if errors.Is(err, os.ErrNotExist) {
+		journal = &ObfuscationJournal{Root: absRoot}
+	} else if err != nil {
+		return nil, err
+	}
+	journal.Timestamp = time.Now().Format(time.RFC3339)
+
+	journaled := make(map[string]struct{}, len(journal.Files))
+	for _, f := range journal.Files {
+		journaled[f.Path] = struct{}{}
+	}
+
+	filenames := make([]string, 0, len(blob))
+	for filename := range blob {
+		// Entries of archives and notebook cells cannot be rewritten in place
+		if IsVirtualPath(filename) {
+			continue
+		}
+		filenames = append(filenames, filename)
+	}
+	sort.Strings(filenames)
+
+	// Plan every file first so a bad entry leaves the worktree untouched
+	type plan struct {
+		file       ObfuscatedFile
+		obfuscated []byte
+	}
+	var plans []plan
+	for _, filename := range filenames {
+		path := filename
+		if !filepath.IsAbs(path) {
+			path = filepath.Join(absRoot, path)
+		}
+		if _, ok := journaled[path]; ok {
+			return nil, fmt.Errorf("%s is already obfuscated, run restore first", path)
+		}
+		info, err := os.Stat(path)
+		if err != nil {
+			return nil, fmt.Errorf("unable to stat %s: %w", path, err)
+		}
+		content, err := os.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("unable to read %s: %w", path, err)
+		}
+		// Files in another encoding are rewritten as UTF-8 text and encoded back
+		text, line := content, blob[filename]
+		decoded, enc, bom, ok := decodeReversible(content)
+		if enc != EncodingUTF8 {
+			if !ok {
+				log.Printf("[core.obfuscation] skipping %s: its %s text cannot be written back unchanged", path, enc)
+				continue
+			}
+			text, line = decoded, decodedColumns(line, decoded, enc, bom)
+		}
+		entries, err := obfuscatePerLine(text, line)
+		if err != nil {
+			return nil, fmt.Errorf("failed to obfuscate %s: %w", path, err)
+		}
+		if len(entries) == 0 {
+			continue
+		}
+		file := ObfuscatedFile{
+			Path:         path,
+			Mode:         info.Mode().Perm(),
+			OriginalHash: hashContent(content),
+			Entries:      entries,
+		}
+		obfuscated := applyEntries(text, entries)
+		if enc != EncodingUTF8 {
+			file.Encoding, file.BOM = enc, bom
+			obfuscated = append(content[:bom:bom], EncodeText(obfuscated, enc)...)
+		}
+		file.ObfuscatedHash = hashContent(obfuscated)
+		plans = append(plans, plan{file: file, obfuscated: obfuscated})
+	}
+
+	for _, p := range plans {
+		journal.Files = append(journal.Files, p.file)
+	}
+	// Journal is written before any file so a crash never loses a secret
+	if err := saveJournal(absRoot, journal); err != nil {
+		return nil, fmt.Errorf("unable to write restore journal: %w", err)
+	}
+	for i, p := range plans {
+		if err := os.WriteFile(p.file.Path, p.obfuscated, p.file.Mode); err != nil {
+			// Unwritten files leave the journal, the failed one too unless
+			// the write got far enough to change it
+			written := len(journal.Files) - len(plans) + i
+			if content, readErr := os.ReadFile(p.file.Path); readErr != nil || hashContent(content) != p.file.OriginalHash {
+				written++
+			}
+			journal.Files = journal.Files[:written]
+			err = fmt.Errorf("failed to write %s: %w", p.file.Path, err)
+			if saveErr := saveJournal(absRoot, journal); saveErr != nil {
+				err = errors.Join(err, fmt.Errorf("unable to update restore journal: %w", saveErr))
+			}
+			return journal, err
+		}
+	}
+	return journal, nil
+}
+
+// LoadObfuscation obfuscates all secrets previously detected and saved in the filename map.
+func LoadObfuscation(root string) (*ObfuscationJournal, error) {
+	blob, err := LoadFilenameMap(root)
+	if err != nil {
+		return nil, fmt.Errorf("unable to load filename map: %w", err)
+	}
+	return Obfuscate(root, blob)
+}
+
+// UndoObfuscate restores obfuscated files from the journal of root. Files whose
+// content changed since obfuscation are refused and kept in the journal, files
+// already back to their original content count as restored.
+// It returns the restored and refused file paths.
+func UndoObfuscate(root string) (restored []string, refused []string, err error) {
+	absRoot, err := filepath.Abs(root)
+	if err != nil {
+		return nil, nil, err
+	}
+	journal, err := LoadJournal(absRoot)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil, nil, errors.New("no obfuscation journal found")
+		}
+		return nil, nil, err
+	}
+
+	var remaining []ObfuscatedFile
+	for _, f := range journal.Files {
+		content, err := os.ReadFile(f.Path)
+		if err == nil && hashContent(content) == f.OriginalHash {
+			restored = append(restored, f.Path)
+			continue
+		}
+		if err != nil || hashContent(content) != f.ObfuscatedHash {
+			refused = append(refused, f.Path)
+			remaining = append(remaining, f)
+			continue
+		}
+		original, err := f.revert(content)
+		if err != nil || hashContent(original) != f.OriginalHash {
+			refused = append(refused, f.Path)
+			remaining = append(remaining, f)
+			continue
+		}
+		if err := os.WriteFile(f.Path, original, f.Mode); err != nil {
+			refused = append(refused, f.Path)
+			remaining = append(remaining, f)
+			continue
+		}
+		restored = append(restored, f.Path)
+	}
+
+	journal.Files = remaining
+	if err := saveJournal(absRoot, journal); err != nil {
+		return restored, refused, fmt.Errorf("unable to update restore journal: %w", err)
+	}
+	return restored, refused, nil
+}
@@ -0,0 +1,220 @@
+package core
+
+import (
+	"bytes"
+	"encoding/binary"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestSecretSpan(t *testing.T) {
+	tests := []struct {
+		token string
+		want  string
+	}{
+		{`"aB3$kL9@mX2#pQ5!"`, `aB3$kL9@mX2#pQ5!`},
+		{`apiKey="aB3$kL9@mX2#pQ5!",`, `aB3$kL9@mX2#pQ5!`},
+		{`dGVzdHNlY3JldA==`, `dGVzdHNlY3JldA==`},
+	}
+
+	for _, tt := range tests {
+		if got := secretSpan(tt.token); got != tt.want {
+			t.Errorf("secretSpan(%q) = %q, want %q", tt.token, got, tt.want)
+		}
+	}
+}
+
+func TestObfuscate_RoundTrip(t *testing.T) {
+	t.Setenv("GITAEGIS_JOURNAL_DIR", t.TempDir())
+	root := t.TempDir()
+
+	file := filepath.Join(root, "config.go")
+	original := "package main\n\nvar apiKey = \"aB3$kL9@mX2#pQ5!rT8&nV1^wY4*uI7\" // key\n"
+	os.WriteFile(file, []byte(original), 0644)
+
+	blob := map[string]CodeLine{
+		file: {
+			Lines:   []string{`"aB3$kL9@mX2#pQ5!rT8&nV1^wY4*uI7"`},
+			Indexes: []int{3},
+			Columns: []int{14},
+		},
+	}
+
+	journal, err := Obfuscate(root, blob)
+	if err != nil {
+		t.Fatalf("Obfuscate failed: %v", err)
+	}
+	if len(journal.Files) != 1 {
+		t.Fatalf("expected 1 journaled file, got %d", len(journal.Files))
+	}
+
+	data, _ := os.ReadFile(file)
+	content := string(data)
+	if strings.Contains(content, "aB3$kL9") {
+		t.Error("secret should be replaced")
+	}
+	if !strings.Contains(content, `var apiKey = "`+placeholderPrefix) || !strings.Contains(content, `" // key`) {
+		t.Errorf("only the secret span should be replaced, got %q", content)
+	}
+
+	journalPath, _ := JournalPath(root)
+	info, err := os.Stat(journalPath)
+	if err != nil {
+		t.Fatalf("journal should exist: %v", err)
+	}
+	if info.Mode().Perm() != 0600 {
+		t.Errorf("journal should be owner-only, got %v", info.Mode().Perm())
+	}
+
+	if _, err := Obfuscate(root, blob); err == nil {
+		t.Error("obfuscating an already journaled file should be refused")
+	}
+
+	restored, refused, err := UndoObfuscate(root)
+	if err != nil {
+		t.Fatalf("UndoObfuscate failed: %v", err)
+	}
+	if len(restored) != 1 || len(refused) != 0 {
+		t.Errorf("expected 1 restored and 0 refused, got %v / %v", restored, refused)
+	}
+
+	data, _ = os.ReadFile(file)
+	if string(data) != original {
+		t.Errorf("restored content mismatch:\n%s", data)
+	}
+	if _, err := os.Stat(journalPath); !os.IsNotExist(err) {
+		t.Error("journal should be removed once everything is restored")
+	}
+}
+
+func TestObfuscate_UTF16File(t *testing.T) {
+	t.Setenv("GITAEGIS_JOURNAL_DIR", t.TempDir())
+	root := t.TempDir()
+	const secret = "aB3$kL9@mX2#pQ5!rT8&nV1^wY4*uI7"
+
+	ini := filepath.Join(root, "win.ini")
+	original := append([]byte("\xff\xfe"), utf16Bytes("[db]\r\npassword="+secret+"\r\n", binary.LittleEndian)...)
+	os.WriteFile(ini, original, 0644)
+	script := filepath.Join(root, "deploy.sh")
+	os.WriteFile(script, []byte("export TOKEN="+secret+"\n"), 0644)
+
+	var res ScanResult
+	res.Init()
+	blob := map[string]CodeLine{}
+	for _, f := range []string{ini, script} {
+		lines := res.PerLineScan(f, EntropyFilter(4.0))
+		if lines == nil {
+			t.Fatalf("expected a finding in %s", f)
+		}
+		blob[f] = *lines
+	}
+
+	journal, err := Obfuscate(root, blob)
+	if err != nil {
+		t.Fatalf("Obfuscate failed: %v", err)
+	}
+	if len(journal.Files) != 2 {
+		t.Fatalf("expected both files to be journaled, got %+v", journal.Files)
+	}
+	data, _ := os.ReadFile(ini)
+	text, enc, bom := DecodeText(data)
+	if enc != EncodingUTF16LE || bom != 2 || !strings.HasPrefix(string(text), "[db]\r\npassword="+placeholderPrefix) {
+		t.Errorf("expected the secret replaced in UTF-16LE text, got %s %q", enc, text)
+	}
+
+	restored, refused, err := UndoObfuscate(root)
+	if err != nil || len(restored) != 2 || len(refused) != 0 {
+		t.Fatalf("UndoObfuscate = %v, %v, %v", restored, refused, err)
+	}
+	if data, _ := os.ReadFile(ini); !bytes.Equal(data, original) {
+		t.Errorf("restored content mismatch: %q", data)
+	}
+}
+
+func TestUndoObfuscate_RefusesChangedFile(t *testing.T) {
+	t.Setenv("GITAEGIS_JOURNAL_DIR", t.TempDir())
+	root := t.TempDir()
+
+	file := filepath.Join(root, "secret.txt")
+	os.WriteFile(file, []byte("token aB3$kL9@mX2#pQ5!rT8\n"), 0644)
+
+	blob := map[string]CodeLine{
+		file: {Lines: []string{"aB3$kL9@mX2#pQ5!rT8"}, Indexes: []int{1}, Columns: []int{2}},
+	}
+	if _, err := Obfuscate(root, blob); err != nil {
+		t.Fatalf("Obfuscate failed: %v", err)
+	}
+
+	f, _ := os.OpenFile(file, os.O_APPEND|os.O_WRONLY, 0644)
+	f.WriteString("edited\n")
+	f.Close()
+
+	restored, refused, err := UndoObfuscate(root)
+	if err != nil {
+		t.Fatalf("UndoObfuscate failed: %v", err)
+	}
+	if len(restored) != 0 || len(refused) != 1 {
+		t.Errorf("changed file should be refused, got restored=%v refused=%v", restored, refused)
+	}
+	if _, err := LoadJournal(root); err != nil {
+		t.Error("refused file should stay in the journal")
+	}
+}
+
+func TestObfuscate_WriteFailureLeavesJournalRestorable(t *testing.T) {
+	if os.Geteuid() == 0 {
+		t.Skip("read-only files are writable by root")
+	}
+	t.Setenv("GITAEGIS_JOURNAL_DIR", t.TempDir())
+	root := t.TempDir()
+
+	blob := make(map[string]CodeLine)
+	for _, name := range []string{"a.txt", "b.txt", "c.txt"} {
+		file := filepath.Join(root, name)
+		os.WriteFile(file, []byte("token aB3$kL9@mX2#pQ5!rT8\n"), 0644)
+		blob[file] = CodeLine{Lines: []string{"aB3$kL9@mX2#pQ5!rT8"}, Indexes: []int{1}, Columns: []int{7}}
+	}
+	os.Chmod(filepath.Join(root, "b.txt"), 0444)
+
+	journal, err := Obfuscate(root, blob)
+	if err == nil {
+		t.Fatal("expected the read-only file to fail")
+	}
+	if len(journal.Files) != 1 || journal.Files[0].Path != filepath.Join(root, "a.txt") {
+		t.Fatalf("only the written file should stay journaled, got %+v", journal.Files)
+	}
+	restored, refused, err := UndoObfuscate(root)
+	if err != nil || len(restored) != 1 || len(refused) != 0 {
+		t.Errorf("expected the written file restored, got %v / %v, %v", restored, refused, err)
+	}
+}
+
+func TestUndoObfuscate_OriginalContentCountsAsRestored(t *testing.T) {
+	t.Setenv("GITAEGIS_JOURNAL_DIR", t.TempDir())
+	root := t.TempDir()
+
+	file := filepath.Join(root, "secret.txt")
+	original := "token aB3$kL9@mX2#pQ5!rT8\n"
+	os.WriteFile(file, []byte(original), 0644)
+	blob := map[string]CodeLine{
+		file: {Lines: []string{"aB3$kL9@mX2#pQ5!rT8"}, Indexes: []int{1}, Columns: []int{7}},
+	}
+	if _, err := Obfuscate(root, blob); err != nil {
+		t.Fatalf("Obfuscate failed: %v", err)
+	}
+	// As if the write never happened
+	os.WriteFile(file, []byte(original), 0644)
+
+	restored, refused, err := UndoObfuscate(root)
+	if err != nil || len(restored) != 1 || len(refused) != 0 {
+		t.Errorf("expected the file counted as restored, got %v / %v, %v", restored, refused, err)
+	}
+	if _, err := LoadJournal(root); !os.IsNotExist(err) {
+		t.Error("journal should be removed once everything is restored")
+	}
+	if _, err := Obfuscate(root, blob); err != nil {
+		t.Errorf("file should be obfuscatable again: %v", err)
+	}
+}
@@ -0,0 +1,163 @@
+package core
+
+import (
+	_ "embed"
+	"encoding/json"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+//go:embed sitter.json
+var bundledSitterJSON []byte
+
+// lockfileNames are dependency lockfiles, whose integrity hashes look like secrets
+var lockfileNames = map[string]string{
+	"package-lock.json":   "npm",
+	"npm-shrinkwrap.json": "npm",
+	"yarn.lock":           "yarn",
+	"pnpm-lock.yaml":      "pnpm",
+	"bun.lockb":           "bun",
+	"go.sum":              "go",
+	"Cargo.lock":          "cargo",
+	"poetry.lock":         "poetry",
+	"Pipfile.lock":        "pipenv",
+	"uv.lock":             "uv",
+	"composer.lock":       "composer",
+	"Gemfile.lock":        "bundler",
+	"mix.lock":            "mix",
+	"pubspec.lock":        "pub",
+	"packages.lock.json":  "nuget",
+	"flake.lock":          "nix",
+}
+
+// ciFiles are the CI definitions looked up relative to the repository root
+var ciFiles = map[string]string{
+	".github/workflows":       "github-actions",
+	".gitlab-ci.yml":          "gitlab-ci",
+	".circleci/config.yml":    "circleci",
+	"Jenkinsfile":             "jenkins",
+	"azure-pipelines.yml":     "azure-pipelines",
+	".travis.yml":             "travis",
+	"bitbucket-pipelines.yml": "bitbucket-pipelines",
+	".drone.yml":              "drone",
+	".buildkite":              "buildkite",
+}
+
+// vendoredDirs hold third-party or generated code that is rarely worth scanning
+var vendoredDirs = map[string]bool{
+	"node_modules": true,
+	"vendor":       true,
+	"dist":         true,
+	"build":        true,
+	"target":       true,
+	".venv":        true,
+	"venv":         true,
+	"__pycache__":  true,
+}
+
+// RepoProfile summarises what a repository contains, to tailor a starter config
+type RepoProfile struct {
+	Root      string
+	Languages map[string]int // language name to number of files
+	Lockfiles []string       // repo-relative lockfile paths
+	CI        []string       // detected CI systems
+	Vendored  []string       // repo-relative vendored or generated directories
+	EnvFiles  []string       // repo-relative .env style files
+}
+
+// BundledGrammarConfig returns the extension map shipped with gitaegis
+func BundledGrammarConfig() (*GrammarConfig, error) {
+	var cfg GrammarConfig
+	if err := json.Unmarshal(bundledSitterJSON, &cfg); err != nil {
+		return nil, err
+	}
+	return &cfg, nil
+}
+
+// languageOf returns the language of a file from the grammar map, e.g. python for main.py
+func languageOf(cfg *GrammarConfig, name string) string {
+	grammar, ok := cfg.Filenames[name]
+	if !ok {
+		grammar, ok = cfg.Extensions[strings.ToLower(filepath.Ext(name))]
+	}
+	if !ok {
+		return ""
+	}
+	return strings.TrimSuffix(grammar, ".so")
+}
+
+// ProfileRepo walks root and records its languages, lockfiles, CI systems,
+// vendored directories and env files. Vendored directories are not walked.
+func ProfileRepo(root string) (*RepoProfile, error) {
+	grammars, err := BundledGrammarConfig()
+	if err != nil {
+		return nil, err
+	}
+	p := &RepoProfile{Root: root, Languages: make(map[string]int)}
+
+	err = filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return nil
+		}
+		rel, _ := filepath.Rel(root, path)
+		rel = filepath.ToSlash(rel)
+		name := d.Name()
+		if d.IsDir() {
+			if path == root {
+				return nil
+			}
+			if name == ".git" {
+				return filepath.SkipDir
+			}
+			if vendoredDirs[name] {
+				p.Vendored = append(p.Vendored, rel)
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if _, ok := lockfileNames[name]; ok {
+			p.Lockfiles = append(p.Lockfiles, rel)
+		}
+		if name == ".env" || strings.HasPrefix(name, ".env.") {
+			p.EnvFiles = append(p.EnvFiles, rel)
+		}
+		if lang := languageOf(grammars, name); lang != "" {
+			p.Languages[lang]++
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	for path, system := range ciFiles {
+		if _, err := os.Stat(filepath.Join(root, filepath.FromSlash(path))); err == nil {
+			p.CI = append(p.CI, system)
+		}
+	}
+	sort.Strings(p.CI)
+	return p, nil
+}
+
+// LockfileEcosystem returns the package manager owning a lockfile path
+func LockfileEcosystem(path string) string {
+	return lockfileNames[filepath.Base(path)]
+}
+
+// TopLanguages returns the detected languages, most files first
+func (p *RepoProfile) TopLanguages() []string {
+	langs := make([]string, 0, len(p.Languages))
+	for lang := range p.Languages {
+		langs = append(langs, lang)
+	}
+	sort.Slice(langs, func(i, j int) bool {
+		if p.Languages[langs[i]] != p.Languages[langs[j]] {
+			return p.Languages[langs[i]] > p.Languages[langs[j]]
+		}
+		return langs[i] < langs[j]
+	})
+	return langs
+}
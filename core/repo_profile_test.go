@@ -0,0 +1,46 @@
+package core
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestProfileRepo(t *testing.T) {
+	root := t.TempDir()
+	files := []string{
+		"main.go", "util.go", "scripts/build.py", "go.sum",
+		".github/workflows/ci.yml", ".env.local", "node_modules/pkg/index.js",
+	}
+	for _, f := range files {
+		path := filepath.Join(root, filepath.FromSlash(f))
+		os.MkdirAll(filepath.Dir(path), 0755)
+		os.WriteFile(path, []byte("x\n"), 0644)
+	}
+
+	p, err := ProfileRepo(root)
+	if err != nil {
+		t.Fatalf("ProfileRepo failed: %v", err)
+	}
+	if p.Languages["go"] != 2 || p.Languages["python"] != 1 {
+		t.Errorf("unexpected languages: %v", p.Languages)
+	}
+	if p.Languages["javascript"] != 0 {
+		t.Error("vendored directories should not be walked")
+	}
+	if langs := p.TopLanguages(); langs[0] != "go" {
+		t.Errorf("go should be the top language, got %v", langs)
+	}
+	if len(p.Lockfiles) != 1 || p.Lockfiles[0] != "go.sum" {
+		t.Errorf("unexpected lockfiles: %v", p.Lockfiles)
+	}
+	if len(p.CI) != 1 || p.CI[0] != "github-actions" {
+		t.Errorf("unexpected CI: %v", p.CI)
+	}
+	if len(p.Vendored) != 1 || p.Vendored[0] != "node_modules" {
+		t.Errorf("unexpected vendored dirs: %v", p.Vendored)
+	}
+	if len(p.EnvFiles) != 1 {
+		t.Errorf("unexpected env files: %v", p.EnvFiles)
+	}
+}
@@ -0,0 +1,459 @@
+package core
+
+import (
+	"cmp"
+	"fmt"
+	"log"
+	"maps"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strings"
+
+	toml "github.com/BurntSushi/toml"
+	"gopkg.in/yaml.v3"
+)
+
+// Rule is a detection rule reporting the secret its regex matches under its ID
+type Rule struct {
+	ID          string
+	Description string
+	Regex       *regexp.Regexp
+	// SecretGroup is the capture group holding the secret, 0 for the whole
+	// match or SecretFirstGroup for the first group that matched
+	SecretGroup int
+	// Entropy is the entropy the secret must exceed, 0 disables the check
+	Entropy float64
+	// Keywords gate the regex: it only runs on candidates containing one of
+	// them, ASCII letters matching in either case
+	Keywords []string
+	// Path restricts the rule to matching file paths, nil for every file
+	Path       *regexp.Regexp
+	Severity   Severity
+	Allowlists []RuleAllowlist
+}
+
+// SecretFirstGroup takes the first non-empty capture group of a match as its
+// secret, or the whole match when the regex has no groups, as gitleaks and
+// trufflehog do
+const SecretFirstGroup = -1
+
+// RuleAllowlist suppresses matches of a rule
+type RuleAllowlist struct {
+	Regexes []*regexp.Regexp
+	// RegexTarget is what Regexes are matched against: "secret" (the
+	// default), "match" or "line"
+	RegexTarget string
+	StopWords   []string
+	Paths       []*regexp.Regexp
+}
+
+// allows reports whether the allowlist suppresses a secret found in match
+// of line
+func (a RuleAllowlist) allows(secret string, match string, line string) bool {
+	target := secret
+	switch a.RegexTarget {
+	case "match":
+		target = match
+	case "line":
+		target = line
+	}
+	for _, re := range a.Regexes {
+		if re.MatchString(target) {
+			return true
+		}
+	}
+	lower := strings.ToLower(secret)
+	for _, word := range a.StopWords {
+		if strings.Contains(lower, strings.ToLower(word)) {
+			return true
+		}
+	}
+	return false
+}
+
+// AppliesTo reports whether the rule scans a file: its Path matches and no
+// allowlist excludes the file. path is slash separated and relative to the
+// repository root.
+func (r *Rule) AppliesTo(path string) bool {
+	if r.Path != nil && !r.Path.MatchString(path) {
+		return false
+	}
+	for _, a := range r.Allowlists {
+		for _, re := range a.Paths {
+			if re.MatchString(path) {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// PathScoped reports whether AppliesTo depends on the file
+func (r *Rule) PathScoped() bool {
+	if r.Path != nil {
+		return true
+	}
+	for _, a := range r.Allowlists {
+		if len(a.Paths) > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// secret extracts the secret of a submatch
+func (r *Rule) secret(s string, loc []int) string {
+	if r.SecretGroup > 0 {
+		if 2*r.SecretGroup+1 < len(loc) && loc[2*r.SecretGroup] >= 0 {
+			return s[loc[2*r.SecretGroup]:loc[2*r.SecretGroup+1]]
+		}
+		return ""
+	}
+	if r.SecretGroup == SecretFirstGroup {
+		for g := 1; 2*g+1 < len(loc); g++ {
+			if loc[2*g] >= 0 && loc[2*g+1] > loc[2*g] {
+				return s[loc[2*g]:loc[2*g+1]]
+			}
+		}
+	}
+	return s[loc[0]:loc[1]]
+}
+
+// minPrefixKeyword is the shortest literal prefix of a regex that stands in
+// for the keywords of a rule declaring none
+const minPrefixKeyword = 3
+
+// keywords returns the keywords gating the rule: its own or, when it has
+// none, the literal prefix every match of its regex starts with. Rules
+// without either run on every candidate.
+func (r *Rule) keywords() []string {
+	if len(r.Keywords) > 0 {
+		return r.Keywords
+	}
+	if prefix, _ := r.Regex.LiteralPrefix(); len(prefix) >= minPrefixKeyword {
+		return []string{prefix}
+	}
+	return nil
+}
+
+// contextual reports whether the rule usually matches around its secret,
+// such as a key = 'secret' assignment, and so also runs on the source line
+// of a candidate
+func (r *Rule) contextual() bool {
+	return len(r.Keywords) > 0 || r.SecretGroup != 0
+}
+
+// find returns the secret of the first match of r in the candidate that
+// passes its entropy and allowlists. Contextual rules also match the source
+// line of the candidate, keeping secrets found there that are part of it.
+func (r *Rule) find(c Candidate) (string, bool) {
+	line := c.Source
+	if line == "" {
+		line = c.Text
+	}
+	if secret, ok := r.findIn(c.Text, line, nil); ok {
+		return secret, true
+	}
+	if r.contextual() && c.Source != "" && c.Source != c.Text {
+		return r.findIn(c.Source, line, func(secret string) bool {
+			return strings.Contains(c.Text, secret)
+		})
+	}
+	return "", false
+}
+
+// findIn returns the secret of the first match of r in s, a part of line,
+// that passes its entropy, allowlists and keep, when given
+func (r *Rule) findIn(s string, line string, keep func(secret string) bool) (string, bool) {
+matches:
+	for _, loc := range r.Regex.FindAllStringSubmatchIndex(s, -1) {
+		secret := r.secret(s, loc)
+		if secret == "" || (keep != nil && !keep(secret)) {
+			continue
+		}
+		if r.Entropy > 0 && CalcEntropy(secret) <= r.Entropy {
+			continue
+		}
+		for _, a := range r.Allowlists {
+			if a.allows(secret, s[loc[0]:loc[1]], line) {
+				continue matches
+			}
+		}
+		return secret, true
+	}
+	return "", false
+}
+
+// RuleFilter reports the secret of the first match of r in a candidate that
+// passes its keyword gate, entropy and allowlists, under the rule's ID
+func RuleFilter(r Rule) Filter {
+	return RuleSet([]Rule{r})
+}
+
+// RuleSet combines the RuleFilters of rules as AnyFilters would, but looks
+// for the keywords of every rule in a single pass over each candidate with
+// an Aho-Corasick automaton built once, and only evaluates the regexes of
+// rules whose keywords occur. Keywords match ASCII letters in either case.
+// The keywords of contextual rules are also looked for in the source line.
+func RuleSet(rules []Rule) Filter {
+	var active []Rule
+	for _, r := range rules {
+		if r.Regex != nil {
+			active = append(active, r)
+		}
+	}
+	if len(active) == 0 {
+		return nil
+	}
+
+	var keywords []string
+	var gated [][]int // rules gated by each keyword
+	var always []int  // rules run on every candidate
+	index := make(map[string]int)
+	for i := range active {
+		words := active[i].keywords()
+		if len(words) == 0 || slices.Contains(words, "") {
+			always = append(always, i)
+			continue
+		}
+		for _, word := range words {
+			k, ok := index[word]
+			if !ok {
+				k = len(keywords)
+				index[word] = k
+				keywords = append(keywords, word)
+				gated = append(gated, nil)
+			}
+			gated[k] = append(gated[k], i)
+		}
+	}
+	var matcher *keywordMatcher
+	if len(keywords) > 0 {
+		matcher = newKeywordMatcher(keywords)
+	}
+	contextual := slices.ContainsFunc(active, func(r Rule) bool { return r.contextual() })
+
+	return FilterFunc(func(c Candidate) (Payload, bool) {
+		run := make([]bool, len(active))
+		for _, i := range always {
+			run[i] = true
+		}
+		if matcher != nil {
+			matcher.scan(c.Text, func(k int) {
+				for _, i := range gated[k] {
+					run[i] = true
+				}
+			})
+			if contextual && c.Source != "" && c.Source != c.Text {
+				matcher.scan(c.Source, func(k int) {
+					for _, i := range gated[k] {
+						if active[i].contextual() {
+							run[i] = true
+						}
+					}
+				})
+			}
+		}
+		var merged Payload
+		for i := range active {
+			if !run[i] {
+				continue
+			}
+			if secret, ok := active[i].find(c); ok {
+				if merged == nil {
+					merged = make(Payload)
+				}
+				merged[active[i].ID] = secret
+			}
+		}
+		return merged, merged != nil
+	})
+}
+
+// gitleaksAllowlist is an allowlist table of a gitleaks config
+type gitleaksAllowlist struct {
+	Regexes     []string `toml:"regexes"`
+	RegexTarget string   `toml:"regexTarget"`
+	StopWords   []string `toml:"stopwords"`
+	Paths       []string `toml:"paths"`
+}
+
+// gitleaksConfig is the part of a gitleaks config file that maps to rules
+type gitleaksConfig struct {
+	Extend struct {
+		UseDefault bool   `toml:"useDefault"`
+		Path       string `toml:"path"`
+	} `toml:"extend"`
+	Allowlist gitleaksAllowlist `toml:"allowlist"`
+	Rules     []struct {
+		ID          string              `toml:"id"`
+		Description string              `toml:"description"`
+		Regex       string              `toml:"regex"`
+		SecretGroup int                 `toml:"secretGroup"`
+		Entropy     float64             `toml:"entropy"`
+		Keywords    []string            `toml:"keywords"`
+		Path        string              `toml:"path"`
+		Allowlist   *gitleaksAllowlist  `toml:"allowlist"`
+		Allowlists  []gitleaksAllowlist `toml:"allowlists"`
+	} `toml:"rules"`
+}
+
+// trufflehogConfig is the custom detector list of a trufflehog config file
+type trufflehogConfig struct {
+	Detectors []struct {
+		Name     string            `yaml:"name"`
+		Keywords []string          `yaml:"keywords"`
+		Regex    map[string]string `yaml:"regex"`
+		Entropy  float64           `yaml:"entropy"`
+		Exclude  []string          `yaml:"exclude_regexes_match"`
+	} `yaml:"detectors"`
+}
+
+// compileAll compiles a list of patterns
+func compileAll(patterns []string) ([]*regexp.Regexp, error) {
+	out := make([]*regexp.Regexp, 0, len(patterns))
+	for _, p := range patterns {
+		re, err := regexp.Compile(p)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, re)
+	}
+	return out, nil
+}
+
+// compileAllowlist turns a gitleaks allowlist into a RuleAllowlist
+func compileAllowlist(a gitleaksAllowlist) (RuleAllowlist, error) {
+	regexes, err := compileAll(a.Regexes)
+	if err != nil {
+		return RuleAllowlist{}, err
+	}
+	paths, err := compileAll(a.Paths)
+	if err != nil {
+		return RuleAllowlist{}, err
+	}
+	return RuleAllowlist{Regexes: regexes, RegexTarget: a.RegexTarget, StopWords: a.StopWords, Paths: paths}, nil
+}
+
+// parseGitleaksRules reads the [[rules]] of a gitleaks TOML config. Rules
+// without a regex, which match file names only, are skipped.
+func parseGitleaksRules(data []byte, name string) ([]Rule, error) {
+	var cfg gitleaksConfig
+	if _, err := toml.Decode(string(data), &cfg); err != nil {
+		return nil, err
+	}
+	if cfg.Extend.UseDefault || cfg.Extend.Path != "" {
+		log.Printf("[core.rules] %s: [extend] is not supported, only its own rules are loaded", name)
+	}
+	global, err := compileAllowlist(cfg.Allowlist)
+	if err != nil {
+		return nil, fmt.Errorf("allowlist: %w", err)
+	}
+
+	var rules []Rule
+	for i, raw := range cfg.Rules {
+		if raw.ID == "" {
+			return nil, fmt.Errorf("rules[%d]: missing id", i)
+		}
+		if raw.Regex == "" {
+			log.Printf("[core.rules] %s: skipping %s, rules without a regex are not supported", name, raw.ID)
+			continue
+		}
+		re, err := regexp.Compile(raw.Regex)
+		if err != nil {
+			return nil, fmt.Errorf("rule %s: %w", raw.ID, err)
+		}
+		if raw.SecretGroup < 0 || raw.SecretGroup > re.NumSubexp() {
+			return nil, fmt.Errorf("rule %s: secretGroup %d does not exist", raw.ID, raw.SecretGroup)
+		}
+		r := Rule{
+			ID:          raw.ID,
+			Description: raw.Description,
+			Regex:       re,
+			SecretGroup: cmp.Or(raw.SecretGroup, SecretFirstGroup),
+			Entropy:     raw.Entropy,
+			Keywords:    raw.Keywords,
+			Severity:    SeverityHigh,
+			Allowlists:  []RuleAllowlist{global},
+		}
+		if raw.Path != "" {
+			if r.Path, err = regexp.Compile(raw.Path); err != nil {
+				return nil, fmt.Errorf("rule %s: path: %w", raw.ID, err)
+			}
+		}
+		lists := raw.Allowlists
+		if raw.Allowlist != nil {
+			lists = append(lists, *raw.Allowlist)
+		}
+		for _, a := range lists {
+			allow, err := compileAllowlist(a)
+			if err != nil {
+				return nil, fmt.Errorf("rule %s: allowlist: %w", raw.ID, err)
+			}
+			r.Allowlists = append(r.Allowlists, allow)
+		}
+		rules = append(rules, r)
+	}
+	return rules, nil
+}
+
+// parseTrufflehogRules reads the custom detectors of a trufflehog YAML
+// config, one rule per regex named detector.key when a detector has several
+func parseTrufflehogRules(data []byte) ([]Rule, error) {
+	var cfg trufflehogConfig
+	if err := yaml.Unmarshal(data, &cfg); err != nil {
+		return nil, err
+	}
+	var rules []Rule
+	for i, d := range cfg.Detectors {
+		if d.Name == "" {
+			return nil, fmt.Errorf("detectors[%d]: missing name", i)
+		}
+		exclude, err := compileAll(d.Exclude)
+		if err != nil {
+			return nil, fmt.Errorf("detector %s: exclude_regexes_match: %w", d.Name, err)
+		}
+		for _, key := range slices.Sorted(maps.Keys(d.Regex)) {
+			re, err := regexp.Compile(d.Regex[key])
+			if err != nil {
+				return nil, fmt.Errorf("detector %s: regex %s: %w", d.Name, key, err)
+			}
+			id := d.Name
+			if len(d.Regex) > 1 {
+				id += "." + key
+			}
+			rules = append(rules, Rule{
+				ID:          id,
+				Regex:       re,
+				SecretGroup: SecretFirstGroup,
+				Entropy:     d.Entropy,
+				Keywords:    d.Keywords,
+				Severity:    SeverityHigh,
+				Allowlists:  []RuleAllowlist{{Regexes: exclude, RegexTarget: "match"}},
+			})
+		}
+	}
+	return rules, nil
+}
+
+// LoadRuleFile loads the rules of a gitleaks TOML config or, for .yaml and
+// .yml files, the custom detectors of a trufflehog config
+func LoadRuleFile(path string) ([]Rule, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var rules []Rule
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".yaml", ".yml":
+		rules, err = parseTrufflehogRules(data)
+	default:
+		rules, err = parseGitleaksRules(data, path)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return rules, nil
+}
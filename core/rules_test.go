@@ -0,0 +1,184 @@
+package core
+
+import (
+	"os"
+	"path/filepath"
+	"regexp"
+	"testing"
+)
+
+func TestLoadRuleFile_Gitleaks(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "gitleaks.toml")
+	content := `
+[allowlist]
+stopwords = ["dummy"]
+
+[[rules]]
+id = "acme-token"
+description = "Acme API token"
+regex = '''acme[_-]?token\s*=\s*"(ac_[a-z0-9]{16})"'''
+secretGroup = 1
+keywords = ["acme"]
+
+[[rules]]
+id = "generic-hex"
+regex = '''\b[0-9a-f]{24}\b'''
+entropy = 3.5
+path = '''\.env$'''
+[[rules.allowlists]]
+regexes = ['''^0+$''']
+paths = ['''^test/''']
+
+[[rules]]
+id = "filename-only"
+path = '''id_rsa$'''
+`
+	os.WriteFile(path, []byte(content), 0644)
+
+	rules, err := LoadRuleFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(rules) != 2 || rules[0].ID != "acme-token" || rules[1].ID != "generic-hex" {
+		t.Fatalf("unexpected rules: %+v", rules)
+	}
+
+	acme := TextFilter(RuleFilter(rules[0]))
+	pl, ok := acme(`acme_token = "ac_0123456789abcdef"`)
+	if !ok || pl["acme-token"] != "ac_0123456789abcdef" {
+		t.Errorf("expected the secret group, got %v %v", pl, ok)
+	}
+	if _, ok := acme(`ACME_TOKEN = "ac_dummy0123456789"`); ok {
+		t.Error("global stopword should suppress the match")
+	}
+	if _, ok := acme(`token = "ac_0123456789abcdef"`); ok {
+		t.Error("match without a keyword should be skipped")
+	}
+
+	hex := rules[1]
+	if !hex.PathScoped() || !hex.AppliesTo("deploy/.env") || hex.AppliesTo("main.go") || hex.AppliesTo("test/.env") {
+		t.Error("unexpected path scoping")
+	}
+	if _, ok := TextFilter(RuleFilter(hex))("000000000000000000000000"); ok {
+		t.Error("low entropy secret should be skipped")
+	}
+	if _, ok := TextFilter(RuleFilter(hex))("3f9a1c7e5b2d8f4a6c0e9b7d"); !ok {
+		t.Error("expected the hex secret to match")
+	}
+}
+
+func TestLoadRuleFile_Trufflehog(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "detectors.yaml")
+	content := `
+detectors:
+  - name: internal
+    keywords: [internal]
+    regex:
+      id: 'internal_id_([a-z0-9]{8})'
+      secret: 'internal_secret_([a-z0-9]{12})'
+    exclude_regexes_match:
+      - 'internal_secret_000000000000'
+`
+	os.WriteFile(path, []byte(content), 0644)
+
+	rules, err := LoadRuleFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(rules) != 2 || rules[0].ID != "internal.id" || rules[1].ID != "internal.secret" {
+		t.Fatalf("unexpected rules: %+v", rules)
+	}
+	secret := TextFilter(RuleFilter(rules[1]))
+	if pl, ok := secret("internal_secret_a1b2c3d4e5f6"); !ok || pl["internal.secret"] != "a1b2c3d4e5f6" {
+		t.Errorf("expected a match, got %v %v", pl, ok)
+	}
+	if _, ok := secret("internal_secret_000000000000"); ok {
+		t.Error("excluded match should be skipped")
+	}
+}
+
+func TestLoadRuleFile_Invalid(t *testing.T) {
+	dir := t.TempDir()
+	tests := map[string]string{
+		"bad-regex.toml": "[[rules]]\nid = \"x\"\nregex = '(unclosed'\n",
+		"bad-group.toml": "[[rules]]\nid = \"x\"\nregex = 'a(b)'\nsecretGroup = 2\n",
+		"no-id.toml":     "[[rules]]\nregex = 'a'\n",
+		"no-name.yaml":   "detectors:\n  - regex:\n      a: 'b'\n",
+	}
+	for name, content := range tests {
+		path := filepath.Join(dir, name)
+		os.WriteFile(path, []byte(content), 0644)
+		if _, err := LoadRuleFile(path); err == nil {
+			t.Errorf("%s: expected an error", name)
+		}
+	}
+}
+
+func TestRuleFilter_SecretGroup(t *testing.T) {
+	re := regexp.MustCompile(`(key|token)=(\w+)`)
+	tests := []struct {
+		group int
+		want  string
+	}{
+		{0, "token=abc123"},
+		{2, "abc123"},
+		{SecretFirstGroup, "token"},
+	}
+	for _, tt := range tests {
+		pl, ok := TextFilter(RuleFilter(Rule{ID: "r", Regex: re, SecretGroup: tt.group}))("token=abc123")
+		if !ok || pl["r"] != tt.want {
+			t.Errorf("SecretGroup %d: got %v, want %q", tt.group, pl, tt.want)
+		}
+	}
+}
+
+func TestRuleFilter_SourceLine(t *testing.T) {
+	const secret = "k8Vq2Lr9Zx4Pw7Mn3"
+	rule := Rule{ID: "api_key", Regex: regexp.MustCompile(`api_key\s*=\s*'(.+)'`), SecretGroup: 1}
+	filter := RuleFilter(rule)
+	tests := []struct {
+		name      string
+		candidate Candidate
+		want      bool
+	}{
+		{"token in the assignment", Candidate{Text: "'" + secret + "'", Source: "api_key = '" + secret + "'"}, true},
+		{"other token of the line", Candidate{Text: "api_key", Source: "api_key = '" + secret + "'"}, false},
+		{"no source line", Candidate{Text: "'" + secret + "'"}, false},
+	}
+	for _, tt := range tests {
+		pl, ok := filter.Check(tt.candidate)
+		if ok != tt.want || (ok && pl["api_key"] != secret) {
+			t.Errorf("%s: got %v, %v", tt.name, pl, ok)
+		}
+	}
+
+	if _, ok := RuleFilter(Rule{ID: "plain", Regex: regexp.MustCompile(`api_key\s*=\s*'.+'`)}).Check(tests[0].candidate); ok {
+		t.Error("rules without keywords or a secret group should only match the token")
+	}
+
+	// The same assignment found by the line scan of a file
+	path := filepath.Join(t.TempDir(), "settings.py")
+	os.WriteFile(path, []byte("DEBUG = True\napi_key = '"+secret+"'\n"), 0644)
+	var res ScanResult
+	res.Init()
+	lines := res.PerLineScan(path, filter)
+	if lines == nil || len(lines.Lines) != 1 || lines.Indexes[0] != 2 || lines.Extracted[0]["api_key"] != secret {
+		t.Errorf("expected the assignment on line 2, got %+v", lines)
+	}
+}
+
+func TestRuleAllowlist_LineTarget(t *testing.T) {
+	rule := Rule{
+		ID:         "acme",
+		Regex:      regexp.MustCompile(`ac_[a-z0-9]{16}`),
+		Allowlists: []RuleAllowlist{{Regexes: []*regexp.Regexp{regexp.MustCompile(`#\s*nosec`)}, RegexTarget: "line"}},
+	}
+	filter := RuleFilter(rule)
+	const secret = "ac_0123456789abcdef"
+	if _, ok := filter.Check(Candidate{Text: secret, Source: "key = " + secret + " # nosec"}); ok {
+		t.Error("allowlisted line should suppress the match")
+	}
+	if _, ok := filter.Check(Candidate{Text: secret, Source: "key = " + secret}); !ok {
+		t.Error("other lines should keep the match")
+	}
+}
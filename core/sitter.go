@@ -141,30 +141,71 @@ func initGrammar(filename string) *sitter.Parser {
 	return parser
 }
 
-// createTree parses a file and returns both the syntax tree and file content
-func CreateTree(filename string) (*sitter.Tree, []byte, error) {
-	parser := initGrammar(filename)
-	if parser == nil {
-		return nil, nil, fmt.Errorf("failed to initialize grammar")
+// CreateTree parses a file and returns the syntax tree, the file content
+// decoded to UTF-8, and the encoding and BOM length of the original file
+func CreateTree(filename string) (*sitter.Tree, []byte, TextEncoding, int, error) {
+	raw, err := os.ReadFile(filename)
+	if err != nil {
+		return nil, nil, "", 0, err
 	}
+	data, enc, bom := DecodeText(raw)
 
-	data, err := os.ReadFile(filename)
+	tree, err := parseSource(filename, data)
 	if err != nil {
-		return nil, nil, err
+		return nil, nil, "", 0, err
 	}
+	return tree, data, enc, bom, nil
+}
 
-	tree, err := parser.ParseCtx(context.Background(), nil, data)
-	if err != nil {
-		return nil, nil, err
+// parseSource parses code with the grammar of the language name is written in
+func parseSource(name string, code []byte) (*sitter.Tree, error) {
+	parser := initGrammar(name)
+	if parser == nil {
+		return nil, fmt.Errorf("failed to initialize grammar")
+	}
+	return parser.ParseCtx(context.Background(), nil, code)
+}
+
+// parentFields are the fields of syntax nodes naming what their value is
+// assigned or keyed to, across the bundled grammars
+var parentFields = []string{"left", "name", "key", "field"}
+
+// maxParentDepth bounds how far up the syntax tree a parent identifier is looked for
+const maxParentDepth = 4
+
+// parentIdentifier returns the name n is assigned or keyed to: the left side,
+// name or key of the closest enclosing node having one outside n
+func parentIdentifier(n *sitter.Node, code []byte) string {
+	p := n.Parent()
+	for depth := 0; p != nil && depth < maxParentDepth; depth++ {
+		for _, field := range parentFields {
+			child := p.ChildByFieldName(field)
+			if child == nil || (child.StartByte() <= n.StartByte() && n.EndByte() <= child.EndByte()) {
+				continue
+			}
+			return strings.Trim(child.Content(code), "\"'`")
+		}
+		p = p.Parent()
+	}
+	return ""
+}
+
+// lineOf returns the line of src holding the byte at offset
+func lineOf(src string, offset int) string {
+	start := strings.LastIndexByte(src[:offset], '\n') + 1
+	end := strings.IndexByte(src[offset:], '\n')
+	if end < 0 {
+		return src[start:]
 	}
-	return tree, data, nil
+	return src[start : offset+end]
 }
 
 // Run a DFS to walk through the tree and get leaf node
-func walkParse(root *sitter.Node, filter LineFilter, code []byte) *CodeLine {
+func walkParse(root *sitter.Node, filter Filter, code []byte) *CodeLine {
 	var lines []string
 	var indexes, columns []int
 	var extracted []Payload
+	src := string(code)
 
 	stack := []*sitter.Node{root}
 
@@ -176,8 +217,16 @@ func walkParse(root *sitter.Node, filter LineFilter, code []byte) *CodeLine {
 		// If leaf node, process it
 		if n.ChildCount() == 0 {
 			content := n.Content(code)
-			if pl, ok := filter(content); ok {
-				start := n.StartPoint()
+			start := n.StartPoint()
+			pl, ok := filter.Check(Candidate{
+				Text:     content,
+				NodeKind: n.Type(),
+				Parent:   parentIdentifier(n, code),
+				Line:     int(start.Row) + 1,
+				Column:   int(start.Column) + 1,
+				Source:   lineOf(src, int(n.StartByte())),
+			})
+			if ok {
 				lines = append(lines, content)
 				indexes = append(indexes, int(start.Row)+1)
 				columns = append(columns, int(start.Column)+1)
@@ -0,0 +1,533 @@
+package core
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	toml "github.com/BurntSushi/toml"
+	"gopkg.in/yaml.v3"
+)
+
+// Config formats parsed natively instead of scanned line by line
+const (
+	FormatJSON = "json"
+	FormatYAML = "yaml"
+	FormatTOML = "toml"
+	FormatEnv  = "env"
+	FormatINI  = "ini"
+)
+
+// locateWindow is how many lines below its hint a value is searched for
+const locateWindow = 50
+
+// structuredValue is a scalar of a config file with its key path and the
+// position its value starts at, 1-based, as far as the parser knows it
+type structuredValue struct {
+	path  string
+	value string
+	line  int
+	col   int
+}
+
+// structuredParsers parse decoded UTF-8 config content into its scalars
+var structuredParsers = map[string]func(data []byte, text textLines) ([]structuredValue, error){
+	FormatJSON: parseJSONValues,
+	FormatYAML: parseYAMLValues,
+	FormatTOML: parseTOMLValues,
+	FormatEnv:  parseEnvValues,
+	FormatINI:  parseINIValues,
+}
+
+// StructuredFormat returns the config format of a file name, or "" for files
+// scanned as plain text
+func StructuredFormat(name string) string {
+	base := strings.ToLower(filepath.Base(name))
+	if base == ".env" || strings.HasPrefix(base, ".env.") || strings.HasSuffix(base, ".env") {
+		return FormatEnv
+	}
+	switch filepath.Ext(base) {
+	case ".json":
+		return FormatJSON
+	case ".yaml", ".yml":
+		return FormatYAML
+	case ".toml":
+		return FormatTOML
+	case ".ini", ".cfg", ".properties":
+		return FormatINI
+	}
+	return ""
+}
+
+// textLines indexes the lines of a document
+type textLines struct {
+	data   []byte
+	starts []int
+}
+
+func newTextLines(data []byte) textLines {
+	starts := []int{0}
+	for i, b := range data {
+		if b == '\n' {
+			starts = append(starts, i+1)
+		}
+	}
+	return textLines{data, starts}
+}
+
+// line returns the text of a 1-based line without its line break
+func (t textLines) line(n int) string {
+	if n < 1 || n > len(t.starts) {
+		return ""
+	}
+	end := len(t.data)
+	if n < len(t.starts) {
+		end = t.starts[n] - 1
+	}
+	return strings.TrimSuffix(string(t.data[t.starts[n-1]:end]), "\r")
+}
+
+// count returns the number of lines
+func (t textLines) count() int {
+	return len(t.starts)
+}
+
+// position turns a byte offset into a 1-based line and byte column
+func (t textLines) position(offset int) (int, int) {
+	n := sort.Search(len(t.starts), func(i int) bool { return t.starts[i] > offset })
+	return n, offset - t.starts[n-1] + 1
+}
+
+// locate finds where token appears at or below a position hint, trying the
+// hinted column first
+func (t textLines) locate(token string, line, col int) (int, int) {
+	if text := t.line(line); col >= 1 && col-1 <= len(text) && strings.HasPrefix(text[col-1:], token) {
+		return line, col
+	}
+	for n := max(line, 1); n <= t.count() && n < line+locateWindow; n++ {
+		if i := strings.Index(t.line(n), token); i >= 0 {
+			return n, i + 1
+		}
+	}
+	return line, col
+}
+
+// joinKey appends a key to a key path
+func joinKey(path string, key string) string {
+	if path == "" {
+		return key
+	}
+	return path + "." + key
+}
+
+// parseJSONValues walks the token stream of a JSON document
+func parseJSONValues(data []byte, text textLines) ([]structuredValue, error) {
+	type frame struct {
+		path      string
+		array     bool
+		index     int
+		key       string
+		expectKey bool
+	}
+	var stack []*frame
+	child := func() string {
+		if len(stack) == 0 {
+			return ""
+		}
+		top := stack[len(stack)-1]
+		if top.array {
+			return fmt.Sprintf("%s[%d]", top.path, top.index)
+		}
+		return joinKey(top.path, top.key)
+	}
+	done := func() {
+		if len(stack) == 0 {
+			return
+		}
+		if top := stack[len(stack)-1]; top.array {
+			top.index++
+		} else {
+			top.expectKey = true
+		}
+	}
+
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	var out []structuredValue
+	for {
+		start := int(dec.InputOffset())
+		tok, err := dec.Token()
+		if err == io.EOF {
+			return out, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		for start < len(data) && strings.IndexByte(" \t\r\n,:", data[start]) >= 0 {
+			start++
+		}
+
+		if len(stack) > 0 && stack[len(stack)-1].expectKey {
+			if key, ok := tok.(string); ok {
+				stack[len(stack)-1].key = key
+				stack[len(stack)-1].expectKey = false
+				continue
+			}
+		}
+		switch t := tok.(type) {
+		case json.Delim:
+			switch t {
+			case '{':
+				stack = append(stack, &frame{path: child(), expectKey: true})
+			case '[':
+				stack = append(stack, &frame{path: child(), array: true})
+			default:
+				stack = stack[:len(stack)-1]
+				done()
+			}
+		case nil:
+			done()
+		default:
+			value := fmt.Sprint(t)
+			line, col := text.position(start)
+			if _, ok := t.(string); ok {
+				col++
+			}
+			out = append(out, structuredValue{child(), value, line, col})
+			done()
+		}
+	}
+}
+
+// parseYAMLValues walks the node tree of every document of a YAML stream
+func parseYAMLValues(data []byte, text textLines) ([]structuredValue, error) {
+	var out []structuredValue
+	var walk func(n *yaml.Node, path string)
+	walk = func(n *yaml.Node, path string) {
+		switch n.Kind {
+		case yaml.DocumentNode:
+			for _, c := range n.Content {
+				walk(c, path)
+			}
+		case yaml.MappingNode:
+			for i := 0; i+1 < len(n.Content); i += 2 {
+				if key := n.Content[i].Value; key == "<<" {
+					walk(n.Content[i+1], path)
+				} else {
+					walk(n.Content[i+1], joinKey(path, key))
+				}
+			}
+		case yaml.SequenceNode:
+			for i, c := range n.Content {
+				walk(c, fmt.Sprintf("%s[%d]", path, i))
+			}
+		case yaml.ScalarNode:
+			if n.Tag == "!!null" {
+				return
+			}
+			// yaml counts columns in characters, reported columns count bytes
+			col, chars := n.Column, 1
+			for i := range text.line(n.Line) {
+				if chars == n.Column {
+					col = i + 1
+					break
+				}
+				chars++
+			}
+			if n.Style == yaml.DoubleQuotedStyle || n.Style == yaml.SingleQuotedStyle {
+				col++
+			}
+			out = append(out, structuredValue{path, n.Value, n.Line, col})
+		}
+	}
+
+	dec := yaml.NewDecoder(bytes.NewReader(data))
+	for {
+		var doc yaml.Node
+		err := dec.Decode(&doc)
+		if err == io.EOF {
+			return out, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		walk(&doc, "")
+	}
+}
+
+// parseTOMLValues decodes a TOML document and finds each value in its text,
+// as the decoder does not report positions
+func parseTOMLValues(data []byte, text textLines) ([]structuredValue, error) {
+	var doc map[string]any
+	if _, err := toml.Decode(string(data), &doc); err != nil {
+		return nil, err
+	}
+	used := make(map[[2]int]bool)
+	var out []structuredValue
+	var walk func(v any, path string, key string)
+	walk = func(v any, path string, key string) {
+		switch v := v.(type) {
+		case map[string]any:
+			keys := make([]string, 0, len(v))
+			for k := range v {
+				keys = append(keys, k)
+			}
+			sort.Strings(keys)
+			for _, k := range keys {
+				walk(v[k], joinKey(path, k), k)
+			}
+		case []map[string]any:
+			for i, m := range v {
+				walk(m, fmt.Sprintf("%s[%d]", path, i), key)
+			}
+		case []any:
+			for i, e := range v {
+				walk(e, fmt.Sprintf("%s[%d]", path, i), key)
+			}
+		default:
+			value := fmt.Sprint(v)
+			line, col := locateTOMLValue(text, key, value, used)
+			out = append(out, structuredValue{path, value, line, col})
+		}
+	}
+	walk(doc, "", "")
+	sort.SliceStable(out, func(i, j int) bool { return out[i].line < out[j].line })
+	return out, nil
+}
+
+// locateTOMLValue finds the first occurrence of value not yet used,
+// preferring occurrences with key before them on their line
+func locateTOMLValue(text textLines, key string, value string, used map[[2]int]bool) (int, int) {
+	for _, needKey := range []bool{true, false} {
+		for n := 1; n <= text.count(); n++ {
+			line := text.line(n)
+			for from := 0; from <= len(line); {
+				i := strings.Index(line[from:], value)
+				if i < 0 {
+					break
+				}
+				i += from
+				from = i + 1
+				if used[[2]int{n, i}] || (needKey && !strings.Contains(line[:i], key)) {
+					continue
+				}
+				used[[2]int{n, i}] = true
+				return n, i + 1
+			}
+		}
+	}
+	return 1, 1
+}
+
+// unquoteValue strips matching quotes from a value, returning how many bytes
+// were dropped in front
+func unquoteValue(raw string) (string, int) {
+	if len(raw) >= 2 && (raw[0] == '"' || raw[0] == '\'') && raw[len(raw)-1] == raw[0] {
+		if raw[0] == '"' {
+			if s, err := strconv.Unquote(raw); err == nil {
+				return s, 1
+			}
+		}
+		return raw[1 : len(raw)-1], 1
+	}
+	return raw, 0
+}
+
+// closingQuote returns the index of the quote closing a quoted value, or -1
+func closingQuote(raw string) int {
+	if raw == "" || (raw[0] != '"' && raw[0] != '\'') {
+		return -1
+	}
+	for i := 1; i < len(raw); i++ {
+		if raw[i] == '\\' && raw[0] == '"' {
+			i++
+			continue
+		}
+		if raw[i] == raw[0] {
+			return i
+		}
+	}
+	return -1
+}
+
+// splitAssignment splits a key=value line at the first separator, returning
+// the value with its byte offset in the line
+func splitAssignment(line string, seps string) (string, string, int, bool) {
+	i := strings.IndexAny(line, seps)
+	if i <= 0 {
+		return "", "", 0, false
+	}
+	key := strings.TrimSpace(line[:i])
+	rest := line[i+1:]
+	offset := i + 1 + len(rest) - len(strings.TrimLeft(rest, " \t"))
+	return key, strings.TrimRight(line[offset:], " \t\r"), offset, key != ""
+}
+
+// parseEnvValues reads KEY=value lines, with optional export and quotes
+func parseEnvValues(data []byte, text textLines) ([]structuredValue, error) {
+	var out []structuredValue
+	for n := 1; n <= text.count(); n++ {
+		line := text.line(n)
+		trimmed := strings.TrimSpace(line)
+		if trimmed == "" || strings.HasPrefix(trimmed, "#") {
+			continue
+		}
+		body := strings.TrimPrefix(strings.TrimLeft(line, " \t"), "export ")
+		start := len(line) - len(body)
+		key, raw, offset, ok := splitAssignment(body, "=")
+		if !ok {
+			return nil, fmt.Errorf("line %d: expected KEY=value", n)
+		}
+		if end := closingQuote(raw); end > 0 {
+			raw = raw[:end+1]
+		} else if i := strings.Index(raw, " #"); i >= 0 {
+			raw = strings.TrimRight(raw[:i], " \t")
+		}
+		value, quote := unquoteValue(raw)
+		out = append(out, structuredValue{key, value, n, start + offset + quote + 1})
+	}
+	return out, nil
+}
+
+// parseINIValues reads [section] headers and key = value or key: value lines
+func parseINIValues(data []byte, text textLines) ([]structuredValue, error) {
+	var out []structuredValue
+	section := ""
+	for n := 1; n <= text.count(); n++ {
+		line := text.line(n)
+		trimmed := strings.TrimSpace(line)
+		switch {
+		case trimmed == "" || trimmed[0] == '#' || trimmed[0] == ';' || trimmed[0] == '!':
+			continue
+		case trimmed[0] == '[':
+			if !strings.HasSuffix(trimmed, "]") {
+				return nil, fmt.Errorf("line %d: unterminated section header", n)
+			}
+			section = strings.TrimSpace(trimmed[1 : len(trimmed)-1])
+			continue
+		}
+		key, raw, offset, ok := splitAssignment(line, "=:")
+		if !ok {
+			return nil, fmt.Errorf("line %d: expected key = value", n)
+		}
+		value, quote := unquoteValue(raw)
+		out = append(out, structuredValue{joinKey(section, key), value, n, offset + quote + 1})
+	}
+	return out, nil
+}
+
+// ScanStructured parses a config file and runs filter over the tokens of each
+// scalar value, recording its key_path, then adds what a line scan finds in
+// comments and keys. Parse errors are returned so callers
+// can fall back to a line scan.
+func (res *ScanResult) ScanStructured(filename string, filter Filter) (*CodeLine, error) {
+	parse, ok := structuredParsers[StructuredFormat(filename)]
+	if !ok || isNilFilter(filter) {
+		return nil, fmt.Errorf("%s is not a structured config file", filename)
+	}
+	raw, err := os.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+	code, enc, bom := DecodeText(raw)
+	if !utf8.Valid(code) {
+		return nil, fmt.Errorf("%s is not text", filename)
+	}
+	text := newTextLines(code)
+	values, err := parse(code, text)
+	if err != nil {
+		return nil, err
+	}
+
+	result := scanValues(values, text, inFile(filter, filename, LanguageOf(filename)), enc)
+	remapColumns(&result, code, enc, bom)
+	// Comments and keys are not values, so the line scan covers them
+	result = mergeLineFindings(result, res.PerLineScan(filename, filter))
+	if len(result.Lines) == 0 {
+		return nil, nil
+	}
+	return &result, nil
+}
+
+// mergeLineFindings adds the findings of a line scan that no value finding
+// on the same line covers, such as secrets in comments or keys, keeping
+// findings ordered by position
+func mergeLineFindings(values CodeLine, lines *CodeLine) CodeLine {
+	if lines == nil {
+		return values
+	}
+	merged := values
+	for i, token := range lines.Lines {
+		covered := false
+		for j, value := range values.Lines {
+			if values.Indexes[j] == lines.Indexes[i] && strings.Contains(token, value) {
+				covered = true
+				break
+			}
+		}
+		if covered {
+			continue
+		}
+		var pl Payload
+		if i < len(lines.Extracted) {
+			pl = lines.Extracted[i]
+		}
+		merged.Lines = append(merged.Lines, token)
+		merged.Indexes = append(merged.Indexes, lines.Indexes[i])
+		merged.Columns = append(merged.Columns, lines.Columns[i])
+		merged.Extracted = append(merged.Extracted, pl)
+	}
+
+	order := make([]int, len(merged.Lines))
+	for i := range order {
+		order[i] = i
+	}
+	sort.SliceStable(order, func(a, b int) bool {
+		if merged.Indexes[order[a]] != merged.Indexes[order[b]] {
+			return merged.Indexes[order[a]] < merged.Indexes[order[b]]
+		}
+		return merged.Columns[order[a]] < merged.Columns[order[b]]
+	})
+	var sorted CodeLine
+	for _, i := range order {
+		sorted.Lines = append(sorted.Lines, merged.Lines[i])
+		sorted.Indexes = append(sorted.Indexes, merged.Indexes[i])
+		sorted.Columns = append(sorted.Columns, merged.Columns[i])
+		sorted.Extracted = append(sorted.Extracted, merged.Extracted[i])
+	}
+	return sorted
+}
+
+// scanValues runs filter over the tokens of each scalar value, recording its
+// key_path and, for transcoded files, the encoding
+func scanValues(values []structuredValue, text textLines, filter Filter, enc TextEncoding) CodeLine {
+	var result CodeLine
+	for _, v := range values {
+		for _, token := range strings.Fields(v.value) {
+			// Candidates carry the position of the value, the token is only
+			// located in the text once it matched
+			col := v.col + strings.Index(v.value, token)
+			pl, ok := filter.Check(Candidate{Text: token, Parent: v.path, Line: v.line, Column: col})
+			if !ok || pl == nil {
+				continue
+			}
+			line, col := text.locate(token, v.line, col)
+			pl["key_path"] = v.path
+			if enc != EncodingUTF8 {
+				pl["encoding"] = string(enc)
+			}
+			result.Lines = append(result.Lines, token)
+			result.Indexes = append(result.Indexes, line)
+			result.Columns = append(result.Columns, col)
+			result.Extracted = append(result.Extracted, pl)
+		}
+	}
+	return result
+}
@@ -0,0 +1,134 @@
+package core
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestScanStructured(t *testing.T) {
+	const secret = "k8Vq2Lr9Zx4Pw7Mn3Tb6Yc1Hd5Gf0Js"
+	type finding struct {
+		path string
+		line int
+	}
+
+	tests := []struct {
+		name    string
+		file    string
+		content string
+		want    []finding
+	}{
+		{"json", "settings.json", `{
+  "database": {
+    "production": {"user": "app", "password": "` + secret + `"}
+  },
+  "keys": [
+    "short",
+    "` + secret + `"
+  ],
+  "enabled": true
+}`, []finding{{"database.production.password", 3}, {"keys[1]", 7}}},
+		{"yaml", "values.yaml", `database:
+  production:
+    password: "` + secret + `"
+servers:
+  - name: a
+    token: ` + secret + `
+note: |
+  first line
+  key ` + secret + `
+`, []finding{{"database.production.password", 3}, {"servers[0].token", 6}, {"note", 9}}},
+		{"toml", "app.toml", `title = "app"
+
+[database.production]
+user = "app"
+password = "` + secret + `"
+
+[[servers]]
+creds = { user = "me", pass = "` + secret + `" }
+`, []finding{{"database.production.password", 5}, {"servers[0].creds.pass", 8}}},
+		{"env", ".env.production", `# deploy settings
+DEBUG=false
+export API_KEY="` + secret + `" # rotated monthly
+DB_PASSWORD=` + secret + `
+`, []finding{{"API_KEY", 3}, {"DB_PASSWORD", 4}}},
+		{"ini", "credentials.ini", `; aws profile
+[default]
+region = eu-west-1
+aws_secret_access_key = ` + secret + `
+`, []finding{{"default.aws_secret_access_key", 4}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			file := filepath.Join(t.TempDir(), tt.file)
+			os.WriteFile(file, []byte(tt.content), 0644)
+
+			result := &ScanResult{}
+			result.Init()
+			lines, err := result.ScanStructured(file, EntropyFilter(4.0))
+			if err != nil {
+				t.Fatalf("ScanStructured failed: %v", err)
+			}
+			if lines == nil || len(lines.Lines) != len(tt.want) {
+				t.Fatalf("expected %d findings, got %+v", len(tt.want), lines)
+			}
+			text := strings.Split(tt.content, "\n")
+			for i, w := range tt.want {
+				col := strings.Index(text[w.line-1], secret) + 1
+				if lines.Extracted[i]["key_path"] != w.path || lines.Indexes[i] != w.line || lines.Columns[i] != col {
+					t.Errorf("finding %d: %s at %d:%d, want %s at %d:%d", i,
+						lines.Extracted[i]["key_path"], lines.Indexes[i], lines.Columns[i], w.path, w.line, col)
+				}
+			}
+		})
+	}
+}
+
+func TestScanStructured_FallsBackOnParseErrors(t *testing.T) {
+	const secret = "k8Vq2Lr9Zx4Pw7Mn3Tb6Yc1Hd5Gf0Js"
+	file := filepath.Join(t.TempDir(), "broken.json")
+	os.WriteFile(file, []byte("{\n  // not json\n  token: "+secret+"\n"), 0644)
+
+	result := &ScanResult{}
+	result.Init()
+	if _, err := result.ScanStructured(file, EntropyFilter(4.0)); err == nil {
+		t.Fatal("expected a parse error")
+	}
+	result.IterFiles([]string{file}, EntropyFilter(4.0), 1<<20)
+	lines, ok := result.filenameMap[file]
+	if !ok || len(lines.Lines) != 1 || lines.Indexes[0] != 3 {
+		t.Fatalf("expected the line scan to find the token, got %+v", lines)
+	}
+	if _, ok := lines.Extracted[0]["key_path"]; ok {
+		t.Error("line scan findings have no key path")
+	}
+}
+
+func TestScanStructured_CommentsAndKeys(t *testing.T) {
+	const secret = "AKIAZ7VQ2LR9ZX4PW7MN"
+	tests := []struct {
+		file    string
+		content string
+		line    int
+	}{
+		{"app.yaml", "db:\n  # rotated: " + secret + "\n  user: app\n", 2},
+		{".env", "DEBUG=false\n# OLD_KEY=" + secret + "\n", 2},
+		{"settings.json", "{\n  \"" + secret + "\": true\n}\n", 2},
+	}
+	for _, tt := range tests {
+		file := filepath.Join(t.TempDir(), tt.file)
+		os.WriteFile(file, []byte(tt.content), 0644)
+
+		result := &ScanResult{}
+		result.Init()
+		lines, err := result.ScanStructured(file, AddTargetRegexPattern("aws", `AKIA[0-9A-Z]{16}`))
+		if err != nil {
+			t.Fatalf("%s: %v", tt.file, err)
+		}
+		if lines == nil || len(lines.Lines) != 1 || lines.Indexes[0] != tt.line {
+			t.Errorf("%s: expected the secret on line %d, got %+v", tt.file, tt.line, lines)
+		}
+	}
+}
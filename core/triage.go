@@ -0,0 +1,290 @@
+package core
+
+import (
+	"bufio"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// AllowMarker on a source line suppresses every finding of that line
+const AllowMarker = "gitaegis:allow"
+
+const (
+	baselineFile  = ".gitaegis.baseline.json"
+	decisionsFile = ".gitaegis.triage.json"
+)
+
+// Decision values recorded by triage
+const (
+	DecisionFalsePositive = "false_positive"
+	DecisionAllowed       = "allowed"
+	DecisionIgnored       = "ignored"
+	DecisionObfuscated    = "obfuscated"
+)
+
+// Finding is a single detected secret flattened out of a CodeLine
+type Finding struct {
+	Path    string
+	Line    int
+	Column  int
+	Text    string
+	Payload Payload
+}
+
+// Fingerprint identifies a finding independently of its line number, so
+// baseline entries survive unrelated edits above the secret.
+func (f Finding) Fingerprint(root string) string {
+	rel := f.Path
+	if r, err := filepath.Rel(root, f.Path); err == nil && filepath.IsAbs(f.Path) {
+		rel = r
+	}
+	sum := sha256.Sum256([]byte(filepath.ToSlash(rel) + "\x00" + f.Text))
+	return hex.EncodeToString(sum[:8])
+}
+
+// Findings flattens a filename map into findings ordered by path and line
+func Findings(blob map[string]CodeLine) []Finding {
+	var findings []Finding
+	for path, lines := range blob {
+		for i, text := range lines.Lines {
+			f := Finding{Path: path, Text: text}
+			if i < len(lines.Indexes) {
+				f.Line = lines.Indexes[i]
+			}
+			if i < len(lines.Columns) {
+				f.Column = lines.Columns[i]
+			}
+			if i < len(lines.Extracted) {
+				f.Payload = lines.Extracted[i]
+			}
+			findings = append(findings, f)
+		}
+	}
+	sort.Slice(findings, func(a, b int) bool {
+		if findings[a].Path != findings[b].Path {
+			return findings[a].Path < findings[b].Path
+		}
+		return findings[a].Line < findings[b].Line
+	})
+	return findings
+}
+
+// BaselineEntry is an accepted finding that future scans skip
+type BaselineEntry struct {
+	Path   string `json:"path"`
+	Line   int    `json:"line"`
+	Reason string `json:"reason"`
+	Added  string `json:"added"`
+}
+
+// Baseline maps finding fingerprints to accepted entries. It is meant to be committed.
+type Baseline struct {
+	Entries map[string]BaselineEntry `json:"entries"`
+}
+
+// LoadBaseline reads the baseline of root, returning an empty one if missing
+func LoadBaseline(root string) (*Baseline, error) {
+	b := &Baseline{Entries: make(map[string]BaselineEntry)}
+	if err := loadJSON(filepath.Join(root, baselineFile), b); err != nil {
+		return nil, err
+	}
+	if b.Entries == nil {
+		b.Entries = make(map[string]BaselineEntry)
+	}
+	return b, nil
+}
+
+// Add records a finding as accepted
+func (b *Baseline) Add(root string, f Finding, reason string) {
+	rel := f.Path
+	if r, err := filepath.Rel(root, f.Path); err == nil && filepath.IsAbs(f.Path) {
+		rel = r
+	}
+	b.Entries[f.Fingerprint(root)] = BaselineEntry{
+		Path:   filepath.ToSlash(rel),
+		Line:   f.Line,
+		Reason: reason,
+		Added:  time.Now().Format(time.RFC3339),
+	}
+}
+
+// Save writes the baseline of root
+func (b *Baseline) Save(root string) error {
+	return saveJSON(filepath.Join(root, baselineFile), b, 0644)
+}
+
+// Decisions are the per-finding triage choices kept between sessions
+type Decisions map[string]string
+
+// LoadDecisions reads the local triage decisions of root
+func LoadDecisions(root string) (Decisions, error) {
+	d := make(Decisions)
+	if err := loadJSON(filepath.Join(root, decisionsFile), &d); err != nil {
+		return nil, err
+	}
+	return d, nil
+}
+
+// Save writes the decisions of root and keeps them out of git
+func (d Decisions) Save(root string) error {
+	if err := saveJSON(filepath.Join(root, decisionsFile), d, 0644); err != nil {
+		return err
+	}
+	return checkAddGitignore(root, decisionsFile)
+}
+
+func loadJSON(path string, v any) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return err
+	}
+	if err := json.Unmarshal(data, v); err != nil {
+		return fmt.Errorf("corrupt %s: %w", path, err)
+	}
+	return nil
+}
+
+func saveJSON(path string, v any, perm os.FileMode) error {
+	data, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, append(data, '\n'), perm)
+}
+
+// commentPrefixes maps extensions to their line comment syntax
+var commentPrefixes = map[string]string{
+	".go": "//", ".js": "//", ".jsx": "//", ".mjs": "//", ".cjs": "//", ".ts": "//", ".tsx": "//",
+	".java": "//", ".kt": "//", ".kts": "//", ".scala": "//", ".swift": "//", ".rs": "//",
+	".c": "//", ".h": "//", ".cpp": "//", ".hpp": "//", ".cs": "//", ".php": "//", ".dart": "//",
+	".py": "#", ".rb": "#", ".sh": "#", ".bash": "#", ".zsh": "#", ".yaml": "#", ".yml": "#",
+	".toml": "#", ".tf": "#", ".hcl": "#", ".r": "#", ".pl": "#", ".ex": "#", ".exs": "#",
+	".sql": "--", ".lua": "--", ".hs": "--", ".ini": ";",
+}
+
+// CanAllowInline reports whether an inline allow comment can be added to a file
+func CanAllowInline(path string) bool {
+	if IsVirtualPath(path) {
+		return false
+	}
+	if base := filepath.Base(path); base == "Dockerfile" || base == "Makefile" || strings.HasPrefix(base, ".env") {
+		return true
+	}
+	_, ok := commentPrefixes[strings.ToLower(filepath.Ext(path))]
+	return ok
+}
+
+// AllowInline appends an allow comment to the line of a finding
+func AllowInline(f Finding) error {
+	prefix, ok := commentPrefixes[strings.ToLower(filepath.Ext(f.Path))]
+	if !ok {
+		if !CanAllowInline(f.Path) {
+			return fmt.Errorf("no comment syntax known for %s", f.Path)
+		}
+		prefix = "#"
+	}
+	info, err := os.Stat(f.Path)
+	if err != nil {
+		return err
+	}
+	content, err := os.ReadFile(f.Path)
+	if err != nil {
+		return err
+	}
+	lines := strings.Split(string(content), "\n")
+	if f.Line < 1 || f.Line > len(lines) {
+		return fmt.Errorf("line %d out of range for %s", f.Line, f.Path)
+	}
+	l := lines[f.Line-1]
+	if strings.Contains(l, AllowMarker) {
+		return nil
+	}
+	cr := strings.HasSuffix(l, "\r")
+	l = strings.TrimSuffix(l, "\r") + " " + prefix + " " + AllowMarker
+	if cr {
+		l += "\r"
+	}
+	lines[f.Line-1] = l
+	return os.WriteFile(f.Path, []byte(strings.Join(lines, "\n")), info.Mode().Perm())
+}
+
+// AddToGitignore adds a path of root to its .gitignore unless already present
+func AddToGitignore(root string, path string) error {
+	rel := path
+	if r, err := filepath.Rel(root, path); err == nil && filepath.IsAbs(path) {
+		rel = r
+	}
+	return checkAddGitignore(root, filepath.ToSlash(rel))
+}
+
+// ReadContext returns the lines around line (1-based) of a file
+func ReadContext(path string, line int, radius int) (first int, lines []string, err error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return 0, nil, err
+	}
+	defer f.Close()
+
+	first = line - radius
+	if first < 1 {
+		first = 1
+	}
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+	for n := 1; scanner.Scan() && n <= line+radius; n++ {
+		if n >= first {
+			lines = append(lines, scanner.Text())
+		}
+	}
+	return first, lines, scanner.Err()
+}
+
+// ApplyBaseline drops findings accepted in the baseline of root or marked
+// with an inline allow comment. It returns the number of dropped findings.
+func (res *ScanResult) ApplyBaseline(root string) (int, error) {
+	baseline, err := LoadBaseline(root)
+	if err != nil {
+		return 0, err
+	}
+
+	res.mutex.Lock()
+	defer res.mutex.Unlock()
+
+	dropped := 0
+	for path, lines := range res.filenameMap {
+		var source []string
+		if content, err := os.ReadFile(path); err == nil {
+			source = strings.Split(string(content), "\n")
+		}
+
+		kept := lines.keep(func(i int) bool {
+			f := Finding{Path: path, Text: lines.Lines[i]}
+			if i < len(lines.Indexes) {
+				f.Line = lines.Indexes[i]
+			}
+			_, accepted := baseline.Entries[f.Fingerprint(root)]
+			allowed := f.Line >= 1 && f.Line <= len(source) && strings.Contains(source[f.Line-1], AllowMarker)
+			if accepted || allowed {
+				dropped++
+				return false
+			}
+			return true
+		})
+		if len(kept.Lines) == 0 {
+			delete(res.filenameMap, path)
+		} else {
+			res.filenameMap[path] = kept
+		}
+	}
+	return dropped, nil
+}
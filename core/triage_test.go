@@ -0,0 +1,53 @@
+package core
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestApplyBaseline(t *testing.T) {
+	root := t.TempDir()
+	file := filepath.Join(root, "config.go")
+	os.WriteFile(file, []byte("package main\nvar a = \"first\"\nvar b = \"second\" // gitaegis:allow\nvar c = \"third\"\n"), 0644)
+
+	result := &ScanResult{}
+	result.Init()
+	result.filenameMap[file] = CodeLine{
+		Lines:   []string{`"first"`, `"second"`, `"third"`},
+		Indexes: []int{2, 3, 4},
+		Columns: []int{9, 9, 9},
+	}
+
+	baseline, _ := LoadBaseline(root)
+	baseline.Add(root, Finding{Path: file, Line: 2, Text: `"first"`}, "test")
+	if err := baseline.Save(root); err != nil {
+		t.Fatalf("Save failed: %v", err)
+	}
+
+	dropped, err := result.ApplyBaseline(root)
+	if err != nil {
+		t.Fatalf("ApplyBaseline failed: %v", err)
+	}
+	if dropped != 2 {
+		t.Errorf("expected 2 dropped findings, got %d", dropped)
+	}
+	kept := result.filenameMap[file]
+	if len(kept.Lines) != 1 || kept.Indexes[0] != 4 {
+		t.Errorf("only the third finding should remain, got %+v", kept)
+	}
+}
+
+func TestAllowInline(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "app.py")
+	os.WriteFile(file, []byte("import os\nTOKEN = 'abc'\n"), 0644)
+
+	if err := AllowInline(Finding{Path: file, Line: 2}); err != nil {
+		t.Fatalf("AllowInline failed: %v", err)
+	}
+	data, _ := os.ReadFile(file)
+	if !strings.Contains(string(data), "TOKEN = 'abc' # gitaegis:allow\n") {
+		t.Errorf("allow comment not added: %q", data)
+	}
+}
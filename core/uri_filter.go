@@ -0,0 +1,103 @@
+package core
+
+import (
+	"net/url"
+	"regexp"
+	"strings"
+)
+
+// URIPayloadKey is the payload key every URIFilter match sets
+const URIPayloadKey = "uri_scheme"
+
+// uriRe finds URLs and DSNs of schemes that carry credentials, JDBC included
+var uriRe = regexp.MustCompile(`(?i)\b(?:jdbc:)?(?:postgres(?:ql)?|mysql|mariadb|mongodb(?:\+srv)?|rediss?|amqps?|sqlserver|https?)://[^\s'"` + "`" + `<>]+`)
+
+// uriParamRe finds credentials passed as query or JDBC properties
+var uriParamRe = regexp.MustCompile(`(?i)[;?&](user|username|uid|password|pwd)=([^;&#]*)`)
+
+// uriCredential is what a URI reveals about its credentials
+type uriCredential struct {
+	scheme   string
+	host     string
+	user     string
+	password string
+}
+
+// parseURICredential splits a URI into scheme, host and credentials, reading
+// the userinfo first and query or JDBC properties after
+func parseURICredential(raw string) uriCredential {
+	raw = strings.TrimRight(raw, ".,;:)]}")
+	sep := strings.Index(raw, "://")
+	c := uriCredential{scheme: strings.ToLower(raw[:sep])}
+
+	target := raw
+	if strings.HasPrefix(c.scheme, "jdbc:") {
+		target = raw[len("jdbc:"):]
+	}
+	if u, err := url.Parse(target); err == nil && u.Host != "" && !strings.Contains(u.Fragment, "@") {
+		c.host = u.Host
+		if u.User != nil {
+			c.user = u.User.Username()
+			c.password, _ = u.User.Password()
+		}
+	} else {
+		// Properties such as ;password= and reserved characters in passwords
+		// trip url.Parse, so the authority is split by hand
+		authority := raw[sep+3:]
+		if end := strings.IndexAny(authority, "/?;"); end >= 0 {
+			authority = authority[:end]
+		}
+		c.host = authority
+		if at := strings.LastIndex(authority, "@"); at >= 0 {
+			c.host = authority[at+1:]
+			c.user, c.password, _ = strings.Cut(authority[:at], ":")
+		}
+	}
+
+	for _, m := range uriParamRe.FindAllStringSubmatch(raw, -1) {
+		switch strings.ToLower(m[1]) {
+		case "password", "pwd":
+			if c.password == "" {
+				c.password = m[2]
+			}
+		default:
+			if c.user == "" {
+				c.user = m[2]
+			}
+		}
+	}
+	return c
+}
+
+// isPlaceholderPassword reports whether a password is missing, a template
+// variable or an obvious stand-in
+func isPlaceholderPassword(p string) bool {
+	if p == "" || strings.ContainsAny(p[:1], "$%<{[*") {
+		return true
+	}
+	return placeholderWords[strings.ToLower(p)] || isRepeat(p)
+}
+
+// URIFilter matches URLs and connection strings (postgres, mysql, mongodb,
+// redis, amqp, http(s) and their JDBC forms) anywhere in a candidate when
+// they embed a password that is not a placeholder. The payload names the
+// scheme, host and user, never the password.
+func URIFilter() LineFilter {
+	return func(s string) (Payload, bool) {
+		if !strings.Contains(s, "://") {
+			return nil, false
+		}
+		for _, raw := range uriRe.FindAllString(s, -1) {
+			c := parseURICredential(raw)
+			if isPlaceholderPassword(c.password) {
+				continue
+			}
+			return Payload{
+				URIPayloadKey: c.scheme,
+				"uri_host":    c.host,
+				"uri_user":    c.user,
+			}, true
+		}
+		return nil, false
+	}
+}
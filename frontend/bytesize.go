@@ -0,0 +1,85 @@
+package frontend
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// ByteSize is a size in bytes. In config files and the environment it is a
+// bare number of kilobytes or a string with a unit, e.g. "2MB" or "512KB".
+type ByteSize int64
+
+const (
+	kilobyte ByteSize = 1 << 10
+	megabyte ByteSize = 1 << 20
+	gigabyte ByteSize = 1 << 30
+)
+
+// byteUnits maps the accepted unit suffixes, all powers of 1024, to their size
+var byteUnits = map[string]ByteSize{
+	"":  kilobyte,
+	"b": 1,
+	"k": kilobyte, "kb": kilobyte, "kib": kilobyte,
+	"m": megabyte, "mb": megabyte, "mib": megabyte,
+	"g": gigabyte, "gb": gigabyte, "gib": gigabyte,
+}
+
+// ParseByteSize parses a size such as "2MB", "1.5 GiB", "300B" or "500",
+// where a number without a unit is in kilobytes
+func ParseByteSize(raw string) (ByteSize, error) {
+	s := strings.TrimSpace(raw)
+	i := 0
+	for i < len(s) && (s[i] == '-' || s[i] == '+' || s[i] == '.' || (s[i] >= '0' && s[i] <= '9')) {
+		i++
+	}
+	n, err := strconv.ParseFloat(s[:i], 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid size %q, expected a number with an optional unit like 500KB or 2MB", raw)
+	}
+	unit, ok := byteUnits[strings.ToLower(strings.TrimSpace(s[i:]))]
+	if !ok {
+		return 0, fmt.Errorf("invalid size %q, unknown unit %q (use B, KB, MB or GB)", raw, strings.TrimSpace(s[i:]))
+	}
+	return ByteSize(n * float64(unit)), nil
+}
+
+// UnmarshalTOML accepts an integer number of kilobytes or a size string
+func (b *ByteSize) UnmarshalTOML(v any) error {
+	switch v := v.(type) {
+	case int64:
+		*b = ByteSize(v) * kilobyte
+		return nil
+	case string:
+		size, err := ParseByteSize(v)
+		if err != nil {
+			return err
+		}
+		*b = size
+		return nil
+	}
+	return fmt.Errorf("invalid size %v, expected a number of kilobytes or a string like \"2MB\"", v)
+}
+
+// UnmarshalText parses a size string, used for environment overrides
+func (b *ByteSize) UnmarshalText(text []byte) error {
+	size, err := ParseByteSize(string(text))
+	if err != nil {
+		return err
+	}
+	*b = size
+	return nil
+}
+
+// String renders the size in the largest unit dividing it evenly
+func (b ByteSize) String() string {
+	switch {
+	case b != 0 && b%gigabyte == 0:
+		return fmt.Sprintf("%dGB", b/gigabyte)
+	case b != 0 && b%megabyte == 0:
+		return fmt.Sprintf("%dMB", b/megabyte)
+	case b != 0 && b%kilobyte == 0:
+		return fmt.Sprintf("%dKB", b/kilobyte)
+	}
+	return fmt.Sprintf("%dB", int64(b))
+}
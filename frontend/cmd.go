@@ -53,7 +53,32 @@ var scanCmd = &cobra.Command{
 		rv.LoggingEnabled, _ = cmd.Flags().GetBool("logging")
 		rv.GitDiffScan, _ = cmd. Flags().GetBool("git-opt")
 
+		// Command line flags take precedence over every config source
+		entLimit := rv.EntropyLimit
 		LazyInitConfig()
+		if err := ConfigError(); err != nil {
+			return fmt.Errorf("invalid configuration:\n%w", err)
+		}
+		if cmd.Flags().Changed("ent_limit") {
+			rv.SetEntropyLimit(entLimit)
+		}
+		if cmd.Flags().Changed("fail-on") {
+			raw, _ := cmd.Flags().GetString("fail-on")
+			severity, err := core.ParseSeverity(raw)
+			if err != nil {
+				return fmt.Errorf("invalid --fail-on: %w", err)
+			}
+			rv.SetFailOn(severity)
+		}
+		if cmd.Flags().Changed("min-confidence") {
+			confidence, _ := cmd.Flags().GetFloat64("min-confidence")
+			if confidence < 0 || confidence > 1 {
+				return fmt.Errorf("invalid --min-confidence: must be between 0 and 1, got %v", confidence)
+			}
+			rv.SetMinConfidence(confidence)
+		}
+		excludes, _ := cmd.Flags().GetStringSlice("exclude")
+		rv.SetExclusions(excludes, nil, "--exclude")
 
 		absPath, _ := filepath.Abs(targetPath)
 		fmt.Println("START SCANNING...")
@@ -68,6 +93,10 @@ var scanCmd = &cobra.Command{
 			fmt.Println("\nSecrets detected!")
 			os.Exit(1)
 		}
+		if !rv.Result.IsFilenameMapEmpty() {
+			fmt.Printf("\nNo finding is %s severity or above.\n", rv.FailOn)
+			return nil
+		}
 		fmt.Println("\nNo secrets found.")
 		return nil
 	},
@@ -76,30 +105,68 @@ var scanCmd = &cobra.Command{
 var gitignoreCmd = &cobra.Command{
 	Use:   "ignore",
 	Short: "Generate/update .gitignore from previous scan.",
+	Long:  "Ignore adds the files flagged by the previous scan to a managed block of the repository's .gitignore, using repo-relative paths and skipping files already ignored.",
 	RunE: func(cmd *cobra.Command, args []string) error {
 		if rv == nil {
 			rv = NewRuntimeConfig()
 		}
-		blob, err := core.LoadFilenameMap(".")
-		if err != nil {
-			return fmt.Errorf("unable to load scan results: %w", err)
+		dryRun, _ := cmd.Flags().GetBool("dry-run")
+		untrack, _ := cmd.Flags().GetBool("untrack")
+		return RunIgnore(dryRun, untrack)
+	},
+}
+
+var obfuscateCmd = &cobra.Command{
+	Use:   "obfuscate",
+	Short: "Replace secrets from the previous scan with placeholders.",
+	Long:  "Obfuscate replaces every secret recorded by the previous scan with a placeholder and keeps a restore journal outside the worktree.",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if rv == nil {
+			rv = NewRuntimeConfig()
 		}
-		if err := core.UpdateGitignore(blob); err != nil {
-			return fmt.Errorf("failed to update .gitignore: %w", err)
+		return RunObfuscate()
+	},
+}
+
+var restoreCmd = &cobra.Command{
+	Use:   "restore",
+	Short: "Restore secrets replaced by obfuscate.",
+	Long:  "Restore puts obfuscated secrets back from the restore journal. Files changed since obfuscation are refused.",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if rv == nil {
+			rv = NewRuntimeConfig()
 		}
-		return nil
+		return RunRestore()
+	},
+}
+
+var fixCmd = &cobra.Command{
+	Use:     "fix",
+	Short:   "Rewrite secrets from the previous scan into environment lookups.",
+	Long:    "Fix rewrites string literal secrets found by the previous scan into the idiomatic environment lookup of the file's language (Go, Python, JavaScript/TypeScript) and appends their values to a git-ignored .env file.",
+	Example: "gitaegis fix --to-env",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		toEnv, _ := cmd.Flags().GetBool("to-env")
+		if !toEnv {
+			return fmt.Errorf("no fix selected, use --to-env")
+		}
+		assumeYes, _ := cmd.Flags().GetBool("yes")
+		return RunEnvFix(assumeYes, os.Stdin)
 	},
 }
 
-//var obfuscateCmd = &cobra.Command{
-//Use:   "obfuscate",
-//Short: "Obfuscate detected secrets in the codebase",
-//Run: func(cmd *cobra.Command, args []string) {
-//if rv == nil { rv = NewRuntimeConfig() }
-// Uncomment when implemented
-// if err := rv.runObfuscate(); err != nil { log.Fatal(err) }
-//},
-//}
+var triageCmd = &cobra.Command{
+	Use:   "triage",
+	Short: "Interactively review findings from the previous scan.",
+	Long:  "Triage opens a terminal UI over the findings saved by 'gitaegis scan -l'. Each finding can be marked as a false positive (baseline or inline allow comment), have its file ignored, or be obfuscated. Decisions are kept between sessions.",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		root, err := os.Getwd()
+		if err != nil {
+			return fmt.Errorf("unable to get current working directory: %w", err)
+		}
+		return intro.RunTriage(root)
+	},
+}
 
 var addCmd = &cobra.Command{
 	Use:   "add",
@@ -117,6 +184,133 @@ var addCmd = &cobra.Command{
 	},
 }
 
+var filterCmd = &cobra.Command{
+	Use:    "filter <clean|smudge> [path]",
+	Short:  "Git clean/smudge filter driver, registered by 'gitaegis init --filter'.",
+	Hidden: true,
+	Args:   cobra.RangeArgs(1, 2),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if rv == nil {
+			rv = NewRuntimeConfig()
+		}
+		var path string
+		if len(args) > 1 {
+			path = args[1]
+		}
+
+		// git reads the filtered blob from stdout, so keep config chatter off it
+		out := os.Stdout
+		os.Stdout = os.Stderr
+		defer func() { os.Stdout = out }()
+
+		LazyInitConfig()
+		if err := ConfigError(); err != nil {
+			return fmt.Errorf("invalid configuration:\n%w", err)
+		}
+		return rv.RunFilter(args[0], path, out)
+	},
+}
+
+var explainCmd = &cobra.Command{
+	Use:   "explain <path>",
+	Short: "Explain why a file is or isn't scanned.",
+	Long:  "Explain checks a file against .gitignore, the built-in exemptions, exclude and include globs from config and --exclude, .gitaegisignore, the executable bit and the size limit, printing the rule that decides.",
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		LazyInitConfig()
+		if err := ConfigError(); err != nil {
+			return fmt.Errorf("invalid configuration:\n%w", err)
+		}
+		excludes, _ := cmd.Flags().GetStringSlice("exclude")
+		rv.SetExclusions(excludes, nil, "--exclude")
+
+		root := scanRoot()
+		if err := rv.Result.SetRoot(root); err != nil {
+			return err
+		}
+		rv.Result.SetResolver(rv.Resolver(root))
+		scanned, reasons := rv.Result.Explain(args[0], rv.UseGitignore, rv.MaxFileSize)
+		if scanned {
+			fmt.Printf("%s is scanned:\n", args[0])
+		} else {
+			fmt.Printf("%s is not scanned:\n", args[0])
+		}
+		for _, r := range reasons {
+			fmt.Printf("  - %s\n", r)
+		}
+		return nil
+	},
+}
+
+var configCmd = &cobra.Command{
+	Use:   "config",
+	Short: "Generate, inspect and validate the gitaegis configuration.",
+}
+
+var configShowCmd = &cobra.Command{
+	Use:   "show",
+	Short: "Print the effective configuration after merging every source.",
+	Long:  "Show prints the configuration merged from defaults, /etc/gitaegis/config.toml, ~/.config/gitaegis/config.toml, every aegis.config.toml from the repository root down to the current directory, --config and GITAEGIS_* environment variables.",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		wd, err := os.Getwd()
+		if err != nil {
+			return fmt.Errorf("unable to get current working directory: %w", err)
+		}
+		cfg, origins, _, err := ResolveConfig(wd, configPath)
+		if err != nil {
+			return err
+		}
+		withOrigin, _ := cmd.Flags().GetBool("origin")
+		PrintConfig(cfg, origins, withOrigin)
+		return nil
+	},
+}
+
+var configValidateCmd = &cobra.Command{
+	Use:   "validate [path]",
+	Short: "Check config files for unknown keys and invalid values.",
+	Long:  "Validate reports syntax errors, unknown keys, invalid regexes, negative sizes, unsupported output formats and missing tree-sitter directories with their line numbers. Without a path the merged configuration of the current directory is checked.",
+	Args:  cobra.MaximumNArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		var err error
+		if len(args) == 1 {
+			if issues := ValidateConfigFile(args[0]); len(issues) > 0 {
+				err = issues
+			}
+		} else {
+			wd, wdErr := os.Getwd()
+			if wdErr != nil {
+				return fmt.Errorf("unable to get current working directory: %w", wdErr)
+			}
+			_, _, _, err = ResolveConfig(wd, configPath)
+		}
+		if err != nil {
+			return fmt.Errorf("invalid configuration:\n%w", err)
+		}
+		fmt.Println("Configuration is valid.")
+		return nil
+	},
+}
+
+var configInitCmd = &cobra.Command{
+	Use:   "init",
+	Short: "Generate an aegis.config.toml tailored to this repository.",
+	Long:  "Init inspects the repository for languages, lockfiles, vendored directories and CI files, then writes a commented aegis.config.toml with defaults, suggested exemptions and a starter target_regex set.",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		wd, err := os.Getwd()
+		if err != nil {
+			return fmt.Errorf("unable to get current working directory: %w", err)
+		}
+		root, err := core.FindRepoRoot(wd)
+		if err != nil {
+			root = wd
+		}
+		interactive, _ := cmd.Flags().GetBool("interactive")
+		force, _ := cmd.Flags().GetBool("force")
+		return RunConfigInit(root, interactive, force, os.Stdin, os.Stdout)
+	},
+}
+
 var uninstallCmd = &cobra.Command{
 	Use:   "uninstall",
 	Short: "Shortcut to uninstall GitAegis from your shell.",
@@ -132,8 +326,13 @@ var initCmd = &cobra.Command{
 		root, _ := os.Getwd()
 		preHook, _ := cmd.Flags().GetBool("prehook")
 		bash, _ := cmd.Flags().GetBool("bash")
+		filter, _ := cmd.Flags().GetBool("filter")
 		if preHook && bash {
 			return fmt.Errorf("flags --prehook and --bash cannot be used together")
+		} else if filter {
+			if err := intro.GitFilterInit(root); err != nil {
+				return fmt.Errorf("failed to init git filter: %w", err)
+			}
 		} else if bash {
 			intro.AttachShellConfig()
 		} else {
@@ -150,15 +349,32 @@ func Init_cmd() *cobra.Command {
 	rv = NewRuntimeConfig()
 
 	rootCmd.Flags().BoolP("version", "v", false, "Show version information")
+	rootCmd.PersistentFlags().StringVar(&configPath, "config", "", "Path to a config file applied over the discovered ones")
 
 	scanCmd.Flags().Float64VarP(&rv.EntropyLimit, "ent_limit", "e", rv.EntropyLimit, "Entropy threshold for secret detection")
 	scanCmd.Flags().BoolP("logging", "l", false, "Enable logging")
 	scanCmd.Flags().BoolP("git-opt", "g", false, "Enable targeted parsing through changed current file")
+	scanCmd.Flags().StringSlice("exclude", nil, "Glob of paths to skip, repeatable")
+	scanCmd.Flags().String("fail-on", "low", "Least severe finding that fails the scan: low, medium, high or critical")
+	scanCmd.Flags().Float64("min-confidence", 0, "Hide findings scored below this confidence, between 0 and 1")
+	explainCmd.Flags().StringSlice("exclude", nil, "Glob of paths to skip, repeatable")
+
+	gitignoreCmd.Flags().Bool("dry-run", false, "Preview the .gitignore change without writing it")
+	gitignoreCmd.Flags().Bool("untrack", false, "Also remove already tracked files from the index (git rm --cached)")
+
+	configShowCmd.Flags().Bool("origin", false, "Show where each value comes from")
+	configInitCmd.Flags().BoolP("interactive", "i", false, "Ask before applying each suggestion")
+	configInitCmd.Flags().BoolP("force", "f", false, "Overwrite an existing aegis.config.toml")
+	configCmd.AddCommand(configInitCmd, configShowCmd, configValidateCmd)
+
+	fixCmd.Flags().Bool("to-env", false, "Move string literal secrets into .env lookups")
+	fixCmd.Flags().BoolP("yes", "y", false, "Write changes without asking for confirmation")
 
 	initCmd.Flags().Bool("prehook", false, "Integrate gitaegis as git pre-hook")
 	initCmd.Flags().Bool("bash", false, "Integrate gitaegis into bashrc")
+	initCmd.Flags().Bool("filter", false, "Register gitaegis as a git clean/smudge filter")
 
-	rootCmd.AddCommand(scanCmd, gitignoreCmd, addCmd, initCmd, uninstallCmd)
+	rootCmd.AddCommand(scanCmd, gitignoreCmd, obfuscateCmd, restoreCmd, fixCmd, triageCmd, addCmd, initCmd, explainCmd, configCmd, filterCmd, uninstallCmd)
 
 	return rootCmd
 }
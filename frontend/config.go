@@ -4,10 +4,13 @@ import (
 	"fmt"
 	"log"
 	"os"
+	"path/filepath"
+	"strings"
 	"sync"
 	"encoding/json"
 
 	toml "github.com/BurntSushi/toml"
+	core "github.com/steverahardjo/gitaegis/core"
 )
 
 // Config represents the structure of the TOML configuration file
@@ -18,19 +21,59 @@ type Config struct {
 	UseGitignore  bool     `toml:"use_gitignore"`
 	Filter        Filter   `toml:"filter"`
 	GitDiffOpt	  bool     `toml:"use_gitdiff"`
+	Binary        string     `toml:"binary"`
+	Exclude       []string   `toml:"exclude"`
+	Include       []string   `toml:"include"`
+	Overrides     []Override `toml:"override"`
+	Archive       Archive    `toml:"archive"`
+	Placeholders  Placeholders `toml:"placeholders"`
+	RuleFiles     []string   `toml:"rule_files"`
+	FailOn        string     `toml:"fail_on"`
+	MinConfidence float64    `toml:"min_confidence"`
+}
+
+// Placeholders section of the TOML config, suppressing findings that look fake
+type Placeholders struct {
+	Mode     string   `toml:"mode"`
+	Disabled []string `toml:"disabled"`
+	Values   []string `toml:"values"`
+}
+
+// Archive section of the TOML config, bounding how archives are unpacked
+type Archive struct {
+	MaxDepth     int   `toml:"max_depth"`
+	MaxTotalSize ByteSize `toml:"max_total_size"`
+	MaxRatio     int64 `toml:"max_ratio"`
+	MaxEntries   int   `toml:"max_entries"`
+}
+
+// Override adjusts the filter settings of files matching its path globs.
+// Zero values inherit; later overrides win over earlier ones.
+type Override struct {
+	Paths       []string `toml:"paths"`
+	EntLimit    float64  `toml:"ent_limit"`
+	MaxFileSize ByteSize `toml:"max_file_size"`
+	Enabled     []string `toml:"enabled"`
+	Disabled    []string `toml:"disabled"`
 }
 
 // Filter section of the TOML config
 type Filter struct {
-	EntLimit    float64           `toml:"ent_limit"`
-	MaxFileSize int               `toml:"max_file_size"`
-	TargetRegex map[string]string `toml:"target_regex"`
+	EntLimit    float64                `toml:"ent_limit"`
+	MaxFileSize ByteSize               `toml:"max_file_size"`
+	DecodeDepth int                    `toml:"decode_depth"`
+	TargetRegex map[string]TargetRegex `toml:"target_regex"`
 }
 
 var (
-	configOnce sync.Once
-	globalConfig *Config
+	configOnce     sync.Once
+	globalConfig   *Config
+	globalOrigins  ConfigOrigins
+	// configErr holds the validation failure of the last load, if any
+	configErr      error
 	defaultCfgPath = "aegis.config.toml"
+	// configPath is an explicit config file given with --config
+	configPath string
 )
 
 // LoadConfig reads and decodes a TOML file into Config
@@ -46,27 +89,85 @@ func LoadConfig(path string) (*Config, error) {
 	}
 	return &cfg, nil
 }
-//use sync.once to init config efficiently only once when aegis.toml is changed
+//use sync.once to init config efficiently only once when aegis.toml is changed.
+//Config files are merged from the system, user and repository levels, then --config and GITAEGIS_* overrides.
 func LazyInitConfig() *Config {
 	configOnce.Do(func() {
-		if _, err := os.Stat(defaultCfgPath); os.IsNotExist(err) {
-			log.Printf("[Config] %s not found — skipping initialization", defaultCfgPath)
+		wd, err := os.Getwd()
+		if err != nil {
+			log.Printf("[Config] unable to get working directory: %v", err)
 			return
 		}
+		if configPath != "" {
+			if _, err := os.Stat(configPath); err != nil {
+				configErr = fmt.Errorf("config file %s: %w", configPath, err)
+				log.Printf("[Config] %v", configErr)
+				return
+			}
+		}
 
-		cfg, err := LoadConfig(defaultCfgPath)
+		cfg, origins, found, err := ResolveConfig(wd, configPath)
 		if err != nil {
-			log.Printf("[Config] failed to load: %v", err)
+			configErr = err
+			log.Printf("[Config] failed to load:\n%v", err)
+			return
+		}
+		if !found {
+			log.Printf("[Config] %s not found — skipping initialization", defaultCfgPath)
 			return
 		}
 
 		globalConfig = cfg
+		globalOrigins = origins
 		globalConfig.IntegrateConfig()
-		log.Printf("[Config] loaded successfully from %s", defaultCfgPath)
+		log.Printf("[Config] loaded successfully from %s", strings.Join(configSources(wd, configPath), ", "))
 	})
 
 	return globalConfig
 }
+// originOf returns the source of a config key, for messages about its values
+func originOf(key string) string {
+	if origin := globalOrigins[key]; origin != "" {
+		return origin
+	}
+	return defaultCfgPath
+}
+
+// ruleFilePaths returns the rule files of c, relative ones resolved against
+// the directory of the config file that listed them
+func (c *Config) ruleFilePaths(origins ConfigOrigins) []string {
+	base := ""
+	if origin := origins["rule_files"]; origin != "" && origin != originDefault && !strings.HasPrefix(origin, "env ") {
+		base = filepath.Dir(origin)
+	}
+	paths := make([]string, len(c.RuleFiles))
+	for i, p := range c.RuleFiles {
+		if base != "" && !filepath.IsAbs(p) {
+			p = filepath.Join(base, p)
+		}
+		paths[i] = p
+	}
+	return paths
+}
+
+// loadRules loads the rules of every rule file, in order
+func (c *Config) loadRules(origins ConfigOrigins) ([]core.Rule, error) {
+	var rules []core.Rule
+	for _, path := range c.ruleFilePaths(origins) {
+		loaded, err := core.LoadRuleFile(path)
+		if err != nil {
+			return nil, err
+		}
+		rules = append(rules, loaded...)
+	}
+	return rules, nil
+}
+
+// ConfigError returns why the configuration failed to load, so commands can fail fast
+func ConfigError() error {
+	return configErr
+}
+
 // IntegrateConfig applies loaded configuration to global state
 func (c *Config) IntegrateConfig() {
     
@@ -98,8 +199,33 @@ func (c *Config) IntegrateConfig() {
     if c.Filter.MaxFileSize > 0 {
         rv.SetMaxFileSize(int64(c.Filter.MaxFileSize))
     }
+    rv.SetDecodeDepth(c.Filter.DecodeDepth)
+    rv.SetExclusions(c.Exclude, nil, originOf("exclude"))
+    rv.SetExclusions(nil, c.Include, originOf("include"))
+    rv.Result.SetBinaryMode(core.BinaryMode(c.Binary))
+    rv.SetOverrides(c.Overrides)
+    rv.Result.SetArchiveLimits(core.ArchiveLimits{
+        MaxDepth:      c.Archive.MaxDepth,
+        MaxTotalBytes: int64(c.Archive.MaxTotalSize),
+        MaxRatio:      c.Archive.MaxRatio,
+        MaxEntries:    c.Archive.MaxEntries,
+    })
+    rv.SetPlaceholders(core.Placeholders{
+        Mode:     core.PlaceholderMode(c.Placeholders.Mode),
+        Disabled: c.Placeholders.Disabled,
+        Values:   c.Placeholders.Values,
+    })
+    if rules, err := c.loadRules(globalOrigins); err != nil {
+        log.Printf("[Config] %v", err)
+    } else {
+        rv.SetRules(rules)
+    }
+    if severity, err := core.ParseSeverity(c.FailOn); err == nil {
+        rv.SetFailOn(severity)
+    }
+    rv.SetMinConfidence(c.MinConfidence)
     if len(c.Filter.TargetRegex) > 0 {
-        rv.SetFilters(c.Filter.TargetRegex)
+        rv.SetTargetRegex(c.Filter.TargetRegex)
     } else {
         fmt.Println("No config is found, use default of 5.0 entrophy limit")
     }
@@ -0,0 +1,257 @@
+package frontend
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	core "github.com/steverahardjo/gitaegis/core"
+)
+
+// starterRegex is a target_regex entry suggested by config init
+type starterRegex struct {
+	Name    string
+	Pattern string
+	Comment string
+}
+
+// commonRegexes are suggested for every repository
+var commonRegexes = []starterRegex{
+	{"aws_access_key", `(A3T[A-Z0-9]|AKIA|ASIA)[A-Z0-9]{16}`, "AWS access key id"},
+	{"github_token", `gh[pousr]_[A-Za-z0-9]{36,}`, "GitHub personal, OAuth and app tokens"},
+	{"slack_token", `xox[abposr]-[A-Za-z0-9-]{10,}`, "Slack bot and user tokens"},
+	{"private_key", `-----BEGIN [A-Z ]*PRIVATE KEY-----`, "PEM private key header"},
+	{"stripe_key", `(sk|rk)_live_[A-Za-z0-9]{24,}`, "Stripe live secret keys"},
+	{"google_api_key", `AIza[0-9A-Za-z_-]{35}`, "Google API key"},
+}
+
+// languageRegexes are suggested when a language or ecosystem is detected
+var languageRegexes = map[string][]starterRegex{
+	"javascript": {{"npm_token", `npm_[A-Za-z0-9]{36}`, "npm access token"}},
+	"typescript": {{"npm_token", `npm_[A-Za-z0-9]{36}`, "npm access token"}},
+	"python":     {{"pypi_token", `pypi-AgEIcHlwaS5vcmc[A-Za-z0-9_-]{50,}`, "PyPI upload token"}},
+	"ruby":       {{"rubygems_key", `rubygems_[a-f0-9]{48}`, "RubyGems API key"}},
+	"php":        {{"packagist_token", `packagist_[ou]u[A-Za-z0-9]{64,}`, "Packagist token"}},
+}
+
+// ciRegexes are suggested when a CI system is detected
+var ciRegexes = map[string][]starterRegex{
+	"gitlab-ci": {{"gitlab_token", `glpat-[A-Za-z0-9_-]{20}`, "GitLab personal access token"}},
+	"circleci":  {{"circleci_token", `CCIPAT_[A-Za-z0-9]{22}_[a-f0-9]{40}`, "CircleCI personal token"}},
+}
+
+// InitOptions are the choices config init writes into the generated file
+type InitOptions struct {
+	EntLimit    float64
+	MaxFileSize ByteSize
+	Regexes     []starterRegex
+	Exemptions  []string
+	JSONOutput  bool
+}
+
+// DefaultInitOptions derives the options suggested for a repository profile
+func DefaultInitOptions(p *core.RepoProfile) InitOptions {
+	defaults := DefaultConfig()
+	opts := InitOptions{
+		EntLimit:    defaults.Filter.EntLimit,
+		MaxFileSize: defaults.Filter.MaxFileSize,
+		JSONOutput:  len(p.CI) > 0,
+	}
+
+	seen := make(map[string]bool)
+	add := func(regexes []starterRegex) {
+		for _, r := range regexes {
+			if !seen[r.Name] {
+				seen[r.Name] = true
+				opts.Regexes = append(opts.Regexes, r)
+			}
+		}
+	}
+	add(commonRegexes)
+	for _, lang := range p.TopLanguages() {
+		add(languageRegexes[lang])
+	}
+	for _, ci := range p.CI {
+		add(ciRegexes[ci])
+	}
+
+	opts.Exemptions = append(opts.Exemptions, p.Lockfiles...)
+	for _, dir := range p.Vendored {
+		opts.Exemptions = append(opts.Exemptions, dir+"/")
+	}
+	sort.Strings(opts.Exemptions)
+	return opts
+}
+
+// RenderInitConfig writes a commented aegis.config.toml for a repository profile
+func RenderInitConfig(p *core.RepoProfile, opts InitOptions) string {
+	var b strings.Builder
+	b.WriteString("# gitaegis configuration, generated by 'gitaegis config init'.\n")
+	if langs := p.TopLanguages(); len(langs) > 0 {
+		parts := make([]string, len(langs))
+		for i, lang := range langs {
+			parts[i] = fmt.Sprintf("%s (%d)", lang, p.Languages[lang])
+		}
+		fmt.Fprintf(&b, "# Detected languages: %s\n", strings.Join(parts, ", "))
+	}
+	if len(p.CI) > 0 {
+		fmt.Fprintf(&b, "# Detected CI: %s\n", strings.Join(p.CI, ", "))
+	}
+	b.WriteString("# Run 'gitaegis config validate' after editing.\n\n")
+
+	b.WriteString("# Print verbose logs while scanning.\n")
+	b.WriteString("logging = false\n\n")
+	b.WriteString("# Skip files matched by .gitignore.\n")
+	b.WriteString("use_gitignore = true\n\n")
+	b.WriteString("# Only scan files reported by 'git status'.\n")
+	b.WriteString("use_gitdiff = false\n\n")
+	if opts.JSONOutput {
+		b.WriteString("# JSON output is easy to consume from CI jobs.\n")
+		b.WriteString("output_format = [\"json\", \"txt\"]\n\n")
+	} else {
+		b.WriteString("# Supported formats: json, txt, html.\n")
+		b.WriteString("output_format = [\"txt\"]\n\n")
+	}
+	b.WriteString("# Path to compiled tree-sitter grammars, leave unset to scan line by line.\n")
+	b.WriteString("# treesitter_source = \"path/to/grammars\"\n\n")
+
+	if len(opts.Exemptions) > 0 {
+		b.WriteString("# Lockfiles hold integrity hashes and vendored directories hold\n")
+		b.WriteString("# third-party code, both of which look like secrets.\n")
+		b.WriteString("exclude = [\n")
+		for _, path := range opts.Exemptions {
+			fmt.Fprintf(&b, "  %q,\n", path)
+		}
+		b.WriteString("]\n\n")
+	} else {
+		b.WriteString("# Globs of paths to skip, see also .gitaegisignore.\n")
+		b.WriteString("# exclude = [\"testdata/\"]\n\n")
+	}
+
+	b.WriteString("[filter]\n")
+	b.WriteString("# Minimum Shannon entropy of a token to be reported.\n")
+	fmt.Fprintf(&b, "ent_limit = %s\n", strconv.FormatFloat(opts.EntLimit, 'f', -1, 64))
+	b.WriteString("# Skip files larger than this, a number of KB or a size like \"2MB\".\n")
+	fmt.Fprintf(&b, "max_file_size = %q\n", opts.MaxFileSize.String())
+	b.WriteString("# Layers of base64, hex or URL encoding decoded and rescanned, 0 disables.\n")
+	fmt.Fprintf(&b, "decode_depth = %d\n", defaultDecodeDepth)
+
+	if len(opts.Regexes) > 0 {
+		b.WriteString("\n# Known credential formats, reported under their name.\n")
+		b.WriteString("[filter.target_regex]\n")
+		for _, r := range opts.Regexes {
+			fmt.Fprintf(&b, "# %s\n%s = '%s'\n", r.Comment, r.Name, r.Pattern)
+		}
+	}
+
+	b.WriteString("\n# Findings that look like placeholders, such as documented example keys,\n")
+	b.WriteString("# ${VARS} or xxxxxxxx: drop, annotate or off.\n")
+	b.WriteString("[placeholders]\n")
+	fmt.Fprintf(&b, "mode = %q\n", core.PlaceholderDrop)
+	return b.String()
+}
+
+// prompter asks questions on in and writes them to out
+type prompter struct {
+	in  *bufio.Reader
+	out io.Writer
+}
+
+func (p *prompter) ask(question string, def string) string {
+	fmt.Fprintf(p.out, "%s [%s]: ", question, def)
+	answer, _ := p.in.ReadString('\n')
+	answer = strings.TrimSpace(answer)
+	if answer == "" {
+		return def
+	}
+	return answer
+}
+
+func (p *prompter) confirm(question string, def bool) bool {
+	hint := "y/N"
+	if def {
+		hint = "Y/n"
+	}
+	for {
+		fmt.Fprintf(p.out, "%s [%s]: ", question, hint)
+		answer, err := p.in.ReadString('\n')
+		switch strings.ToLower(strings.TrimSpace(answer)) {
+		case "":
+			return def
+		case "y", "yes":
+			return true
+		case "n", "no":
+			return false
+		}
+		if err != nil {
+			return def
+		}
+	}
+}
+
+// askInitOptions lets the user adjust the suggested options
+func askInitOptions(opts InitOptions, in io.Reader, out io.Writer) InitOptions {
+	p := &prompter{in: bufio.NewReader(in), out: out}
+
+	for {
+		raw := p.ask("Entropy limit", strconv.FormatFloat(opts.EntLimit, 'f', -1, 64))
+		if v, err := strconv.ParseFloat(raw, 64); err == nil && v >= 0 {
+			opts.EntLimit = v
+			break
+		}
+		fmt.Fprintln(out, "Enter a non-negative number.")
+	}
+	for {
+		raw := p.ask("Maximum file size", opts.MaxFileSize.String())
+		if v, err := ParseByteSize(raw); err == nil && v >= 0 {
+			opts.MaxFileSize = v
+			break
+		}
+		fmt.Fprintln(out, "Enter a size like 500KB or 2MB.")
+	}
+
+	var regexes []starterRegex
+	for _, r := range opts.Regexes {
+		if p.confirm(fmt.Sprintf("Detect %s (%s)?", r.Comment, r.Name), true) {
+			regexes = append(regexes, r)
+		}
+	}
+	opts.Regexes = regexes
+	if len(opts.Exemptions) > 0 && !p.confirm(fmt.Sprintf("Exclude %d suggested path(s)?", len(opts.Exemptions)), true) {
+		opts.Exemptions = nil
+	}
+	opts.JSONOutput = p.confirm("Write JSON output for CI?", opts.JSONOutput)
+	return opts
+}
+
+// RunConfigInit profiles root and writes a tailored aegis.config.toml into it.
+// An existing file is only replaced with force.
+func RunConfigInit(root string, interactive bool, force bool, in io.Reader, out io.Writer) error {
+	path := filepath.Join(root, defaultCfgPath)
+	if _, err := os.Stat(path); err == nil && !force {
+		return fmt.Errorf("%s already exists, use --force to overwrite it", path)
+	}
+
+	profile, err := core.ProfileRepo(root)
+	if err != nil {
+		return fmt.Errorf("failed to inspect repository: %w", err)
+	}
+	opts := DefaultInitOptions(profile)
+	if interactive {
+		opts = askInitOptions(opts, in, out)
+	}
+
+	if err := os.WriteFile(path, []byte(RenderInitConfig(profile, opts)), 0644); err != nil {
+		return fmt.Errorf("failed to write %s: %w", path, err)
+	}
+	if issues := ValidateConfigFile(path); len(issues) > 0 {
+		return fmt.Errorf("generated config is invalid:\n%w", issues)
+	}
+	fmt.Fprintf(out, "Wrote %s with %d target regex(es).\n", path, len(opts.Regexes))
+	return nil
+}
@@ -0,0 +1,352 @@
+package frontend
+
+import (
+	"encoding"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+
+	toml "github.com/BurntSushi/toml"
+	core "github.com/steverahardjo/gitaegis/core"
+)
+
+// envPrefix is the prefix of environment overrides, e.g. GITAEGIS_FILTER_ENT_LIMIT
+const envPrefix = "GITAEGIS_"
+
+// Origin of values that were never set by any source
+const originDefault = "default"
+
+// ConfigOrigins maps each config key (e.g. filter.ent_limit) to where its value came from
+type ConfigOrigins map[string]string
+
+// DefaultConfig returns the configuration used when no source sets a key
+func DefaultConfig() *Config {
+	limits := core.DefaultArchiveLimits()
+	return &Config{
+		UseGitignore: true,
+		Binary:       string(core.BinarySkip),
+		Filter: Filter{
+			EntLimit:    4.5,
+			MaxFileSize: defaultMaxFileSize,
+			DecodeDepth: defaultDecodeDepth,
+		},
+		Archive: Archive{
+			MaxDepth:     limits.MaxDepth,
+			MaxTotalSize: ByteSize(limits.MaxTotalBytes),
+			MaxRatio:     limits.MaxRatio,
+			MaxEntries:   limits.MaxEntries,
+		},
+		Placeholders: Placeholders{Mode: string(core.PlaceholderDrop)},
+		FailOn:       string(core.SeverityLow),
+	}
+}
+
+// systemConfigPath returns the system-wide config file
+func systemConfigPath() string {
+	if p := os.Getenv(envPrefix + "SYSTEM_CONFIG"); p != "" {
+		return p
+	}
+	return "/etc/gitaegis/config.toml"
+}
+
+// userConfigPath returns the per-user config file, $XDG_CONFIG_HOME/gitaegis/config.toml
+// falling back to ~/.config/gitaegis/config.toml on every platform
+func userConfigPath() string {
+	dir := os.Getenv("XDG_CONFIG_HOME")
+	if dir == "" {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return ""
+		}
+		dir = filepath.Join(home, ".config")
+	}
+	return filepath.Join(dir, "gitaegis", "config.toml")
+}
+
+// repoConfigPaths returns every aegis.config.toml from the repository root
+// down to start, so the ones closest to start are applied last.
+func repoConfigPaths(start string) []string {
+	dir, err := filepath.Abs(start)
+	if err != nil {
+		return nil
+	}
+	stop, err := core.FindRepoRoot(dir)
+	if err != nil {
+		stop = dir
+	}
+
+	var paths []string
+	for {
+		p := filepath.Join(dir, defaultCfgPath)
+		if _, err := os.Stat(p); err == nil {
+			paths = append([]string{p}, paths...)
+		}
+		if dir == stop {
+			break
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			break
+		}
+		dir = parent
+	}
+	return paths
+}
+
+// configSources lists the config files to merge, lowest precedence first
+func configSources(start string, explicit string) []string {
+	var sources []string
+	for _, p := range []string{systemConfigPath(), userConfigPath()} {
+		if p == "" {
+			continue
+		}
+		if _, err := os.Stat(p); err == nil {
+			sources = append(sources, p)
+		}
+	}
+	sources = append(sources, repoConfigPaths(start)...)
+	if explicit != "" {
+		sources = append(sources, explicit)
+	}
+	return sources
+}
+
+// ResolveConfig merges defaults, the system, user and repository config files,
+// an explicit --config file and GITAEGIS_* environment overrides, in that
+// order. found is false when no file or environment override was applied.
+// Syntax errors, unknown keys and invalid values are returned as ConfigErrors.
+func ResolveConfig(start string, explicit string) (cfg *Config, origins ConfigOrigins, found bool, err error) {
+	cfg = DefaultConfig()
+	origins = make(ConfigOrigins)
+	for _, key := range configKeys() {
+		origins[key] = originDefault
+	}
+
+	var issues ConfigErrors
+	for _, path := range configSources(start, explicit) {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, nil, false, fmt.Errorf("failed to read config %s: %w", path, err)
+		}
+		var layer Config
+		md, err := toml.Decode(string(data), &layer)
+		if err != nil {
+			issues = append(issues, decodeIssue(path, err))
+			continue
+		}
+		issues = append(issues, unknownKeys(path, string(data), md)...)
+		mergeDefined(cfg, &layer, md, path, origins)
+		found = true
+	}
+
+	applied, err := applyEnvOverrides(cfg, origins)
+	if err != nil {
+		return nil, nil, false, err
+	}
+
+	// Values are validated once merged so each issue points at the winning source
+	issues = append(issues, cfg.Validate(origins)...)
+	if len(issues) > 0 {
+		return nil, nil, false, issues
+	}
+	return cfg, origins, found || applied, nil
+}
+
+// fieldByKey walks a struct value along a dotted toml key path
+func fieldByKey(v reflect.Value, key []string) (reflect.Value, bool) {
+	for _, part := range key {
+		if v.Kind() != reflect.Struct {
+			return reflect.Value{}, false
+		}
+		found := false
+		t := v.Type()
+		for i := 0; i < t.NumField(); i++ {
+			if tomlName(t.Field(i)) == part {
+				v = v.Field(i)
+				found = true
+				break
+			}
+		}
+		if !found {
+			return reflect.Value{}, false
+		}
+	}
+	return v, true
+}
+
+func tomlName(f reflect.StructField) string {
+	name, _, _ := strings.Cut(f.Tag.Get("toml"), ",")
+	return name
+}
+
+// mergeDefined copies every key defined in md from src into dst. Tables are
+// walked into; maps and arrays are replaced as a whole.
+func mergeDefined(dst, src *Config, md toml.MetaData, origin string, origins ConfigOrigins) {
+	done := make(map[string]bool)
+	for _, key := range md.Keys() {
+		for i := 1; i <= len(key); i++ {
+			prefix := key[:i]
+			name := prefix.String()
+			if done[name] {
+				break
+			}
+			dv, ok := fieldByKey(reflect.ValueOf(dst).Elem(), prefix)
+			if !ok {
+				break
+			}
+			if dv.Kind() == reflect.Struct {
+				continue
+			}
+			sv, _ := fieldByKey(reflect.ValueOf(src).Elem(), prefix)
+			dv.Set(sv)
+			origins[name] = origin
+			done[name] = true
+			break
+		}
+	}
+}
+
+// configKeys returns the dotted keys of every settable leaf of Config
+func configKeys() []string {
+	var keys []string
+	var walk func(t reflect.Type, prefix string)
+	walk = func(t reflect.Type, prefix string) {
+		for i := 0; i < t.NumField(); i++ {
+			f := t.Field(i)
+			name := tomlName(f)
+			if name == "" || name == "-" {
+				continue
+			}
+			if prefix != "" {
+				name = prefix + "." + name
+			}
+			if f.Type.Kind() == reflect.Struct {
+				walk(f.Type, name)
+				continue
+			}
+			keys = append(keys, name)
+		}
+	}
+	walk(reflect.TypeOf(Config{}), "")
+	sort.Strings(keys)
+	return keys
+}
+
+// envName returns the environment variable overriding a config key
+func envName(key string) string {
+	return envPrefix + strings.ToUpper(strings.ReplaceAll(key, ".", "_"))
+}
+
+// applyEnvOverrides sets scalar and list keys from GITAEGIS_* variables
+func applyEnvOverrides(cfg *Config, origins ConfigOrigins) (bool, error) {
+	applied := false
+	for _, key := range configKeys() {
+		name := envName(key)
+		raw, ok := os.LookupEnv(name)
+		if !ok {
+			continue
+		}
+		v, _ := fieldByKey(reflect.ValueOf(cfg).Elem(), strings.Split(key, "."))
+		if err := setFromString(v, raw); err != nil {
+			return false, fmt.Errorf("invalid %s: %w", name, err)
+		}
+		origins[key] = "env " + name
+		applied = true
+	}
+	return applied, nil
+}
+
+// setFromString parses raw into a scalar or string-list value
+func setFromString(v reflect.Value, raw string) error {
+	if v.CanAddr() {
+		if u, ok := v.Addr().Interface().(encoding.TextUnmarshaler); ok {
+			return u.UnmarshalText([]byte(raw))
+		}
+	}
+	switch v.Kind() {
+	case reflect.String:
+		v.SetString(raw)
+	case reflect.Bool:
+		b, err := strconv.ParseBool(raw)
+		if err != nil {
+			return err
+		}
+		v.SetBool(b)
+	case reflect.Int, reflect.Int64:
+		n, err := strconv.ParseInt(raw, 10, 64)
+		if err != nil {
+			return err
+		}
+		v.SetInt(n)
+	case reflect.Float64:
+		f, err := strconv.ParseFloat(raw, 64)
+		if err != nil {
+			return err
+		}
+		v.SetFloat(f)
+	case reflect.Slice:
+		if v.Type().Elem().Kind() != reflect.String {
+			return fmt.Errorf("cannot be set from the environment")
+		}
+		var items []string
+		for _, item := range strings.Split(raw, ",") {
+			if item = strings.TrimSpace(item); item != "" {
+				items = append(items, item)
+			}
+		}
+		v.Set(reflect.ValueOf(items))
+	default:
+		return fmt.Errorf("cannot be set from the environment")
+	}
+	return nil
+}
+
+// configValue returns the display value of a key of cfg
+func configValue(cfg *Config, key string) string {
+	v, ok := fieldByKey(reflect.ValueOf(cfg).Elem(), strings.Split(key, "."))
+	if !ok {
+		return ""
+	}
+	switch v.Kind() {
+	case reflect.String:
+		return strconv.Quote(v.String())
+	case reflect.Map:
+		keys := v.MapKeys()
+		parts := make([]string, 0, len(keys))
+		for _, k := range keys {
+			parts = append(parts, fmt.Sprintf("%s = %q", k.String(), fmt.Sprint(v.MapIndex(k).Interface())))
+		}
+		if len(parts) == 0 {
+			return "{}"
+		}
+		sort.Strings(parts)
+		return "{ " + strings.Join(parts, ", ") + " }"
+	case reflect.Slice:
+		parts := make([]string, 0, v.Len())
+		for i := 0; i < v.Len(); i++ {
+			if v.Index(i).Kind() == reflect.Struct {
+				parts = append(parts, fmt.Sprintf("%+v", v.Index(i).Interface()))
+				continue
+			}
+			parts = append(parts, fmt.Sprintf("%q", fmt.Sprint(v.Index(i).Interface())))
+		}
+		return "[" + strings.Join(parts, ", ") + "]"
+	default:
+		return fmt.Sprint(v.Interface())
+	}
+}
+
+// PrintConfig writes every effective key, with its origin when requested
+func PrintConfig(cfg *Config, origins ConfigOrigins, withOrigin bool) {
+	for _, key := range configKeys() {
+		if withOrigin {
+			fmt.Printf("%s = %s\t# %s\n", key, configValue(cfg, key), origins[key])
+		} else {
+			fmt.Printf("%s = %s\n", key, configValue(cfg, key))
+		}
+	}
+}
@@ -0,0 +1,355 @@
+package frontend
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"regexp"
+	"regexp/syntax"
+	"slices"
+	"sort"
+	"strings"
+
+	toml "github.com/BurntSushi/toml"
+	core "github.com/steverahardjo/gitaegis/core"
+)
+
+// supportedOutputFormats are the accepted output_format values
+var supportedOutputFormats = map[string]struct{}{"json": {}, "txt": {}, "html": {}}
+
+// ConfigIssue is a single validation problem, located in its source when known
+type ConfigIssue struct {
+	Source string
+	Line   int
+	Key    string
+	Msg    string
+}
+
+func (i ConfigIssue) String() string {
+	loc := i.Source
+	if i.Line > 0 {
+		loc = fmt.Sprintf("%s:%d", loc, i.Line)
+	}
+	if i.Key != "" {
+		return fmt.Sprintf("%s: %s: %s", loc, i.Key, i.Msg)
+	}
+	return fmt.Sprintf("%s: %s", loc, i.Msg)
+}
+
+// ConfigErrors collects every issue found while validating a configuration
+type ConfigErrors []ConfigIssue
+
+func (e ConfigErrors) Error() string {
+	lines := make([]string, len(e))
+	for i, issue := range e {
+		lines[i] = issue.String()
+	}
+	return strings.Join(lines, "\n")
+}
+
+var (
+	tableHeaderRe = regexp.MustCompile(`^\[\[?\s*([^\]]+?)\s*\]\]?`)
+	keyLineRe     = regexp.MustCompile(`^((?:[A-Za-z0-9_-]+|"[^"]*"|'[^']*')(?:\s*\.\s*(?:[A-Za-z0-9_-]+|"[^"]*"|'[^']*'))*)\s*=`)
+	inlineKeyRe   = regexp.MustCompile(`[{,]\s*([A-Za-z0-9_-]+|"[^"]*"|'[^']*')\s*=`)
+)
+
+// normalizeKey turns a TOML key like `filter . "target_regex"` into filter.target_regex
+func normalizeKey(raw string) string {
+	parts := strings.Split(raw, ".")
+	for i, p := range parts {
+		parts[i] = strings.Trim(strings.TrimSpace(p), `"'`)
+	}
+	return strings.Join(parts, ".")
+}
+
+// keyLines maps every dotted key of a TOML document to the line defining it.
+// Keys of inline tables are mapped to the line of their table, and keys of the
+// i-th [[array]] entry are also mapped as array[i].key.
+func keyLines(content string) map[string]int {
+	lines := make(map[string]int)
+	record := func(key string, line int) {
+		if _, ok := lines[key]; !ok {
+			lines[key] = line
+		}
+	}
+	arrays := make(map[string]int)
+	table, indexed := "", ""
+	for n, line := range strings.Split(content, "\n") {
+		trimmed := strings.TrimSpace(line)
+		if trimmed == "" || strings.HasPrefix(trimmed, "#") {
+			continue
+		}
+		if m := tableHeaderRe.FindStringSubmatch(trimmed); m != nil {
+			table, indexed = normalizeKey(m[1]), ""
+			record(table, n+1)
+			if strings.HasPrefix(trimmed, "[[") {
+				indexed = fmt.Sprintf("%s[%d]", table, arrays[table])
+				arrays[table]++
+				record(indexed, n+1)
+			}
+			continue
+		}
+		m := keyLineRe.FindStringSubmatch(trimmed)
+		if m == nil {
+			continue
+		}
+		var keys []string
+		for _, prefix := range []string{table, indexed} {
+			if prefix == "" && len(keys) > 0 {
+				continue
+			}
+			key := normalizeKey(m[1])
+			if prefix != "" {
+				key = prefix + "." + key
+			}
+			keys = append(keys, key)
+		}
+		for _, key := range keys {
+			record(key, n+1)
+			for _, im := range inlineKeyRe.FindAllStringSubmatch(trimmed[len(m[0]):], -1) {
+				record(key+"."+normalizeKey(im[1]), n+1)
+			}
+		}
+	}
+	return lines
+}
+
+// issueLocator resolves the source and line of a key through its origin
+type issueLocator struct {
+	origins ConfigOrigins
+	cache   map[string]map[string]int
+}
+
+func (l *issueLocator) issue(key string, format string, args ...any) ConfigIssue {
+	source := l.origins[key]
+	issue := ConfigIssue{Source: source, Key: key, Msg: fmt.Sprintf(format, args...)}
+	if source == "" || source == originDefault || strings.HasPrefix(source, "env ") {
+		return issue
+	}
+	lines, ok := l.cache[source]
+	if !ok {
+		data, _ := os.ReadFile(source)
+		lines = keyLines(string(data))
+		l.cache[source] = lines
+	}
+	issue.Line = lines[key]
+	return issue
+}
+
+// issueAt locates an issue on a key nested below originKey, such as one
+// target_regex entry, falling back to the line of originKey
+func (l *issueLocator) issueAt(originKey string, key string, format string, args ...any) ConfigIssue {
+	issue := l.issue(originKey, format, args...)
+	issue.Key = key
+	if line := l.cache[issue.Source][key]; line > 0 {
+		issue.Line = line
+	}
+	return issue
+}
+
+// regexIssue describes a regex compile error with the column of the bad expression
+func regexIssue(pattern string, err error) string {
+	var serr *syntax.Error
+	if errors.As(err, &serr) {
+		if at := strings.Index(pattern, serr.Expr); at >= 0 && serr.Expr != "" {
+			return fmt.Sprintf("invalid regex at column %d: %s: `%s`", at+1, serr.Code, serr.Expr)
+		}
+		return fmt.Sprintf("invalid regex: %s", serr.Code)
+	}
+	return fmt.Sprintf("invalid regex: %v", err)
+}
+
+// unknownKeys reports keys of a decoded file that Config does not define
+func unknownKeys(path string, content string, md toml.MetaData) ConfigErrors {
+	var issues ConfigErrors
+	lines := keyLines(content)
+	for _, key := range md.Undecoded() {
+		name := key.String()
+		issues = append(issues, ConfigIssue{Source: path, Line: lines[name], Key: name, Msg: "unknown key"})
+	}
+	return issues
+}
+
+// decodeIssue converts a TOML syntax error into an issue with its line
+func decodeIssue(path string, err error) ConfigIssue {
+	var perr toml.ParseError
+	if errors.As(err, &perr) {
+		return ConfigIssue{Source: path, Line: perr.Position.Line, Msg: perr.Message}
+	}
+	return ConfigIssue{Source: path, Msg: err.Error()}
+}
+
+// Validate checks the values of a merged configuration, locating each issue
+// in the source that set the offending key.
+func (c *Config) Validate(origins ConfigOrigins) ConfigErrors {
+	loc := &issueLocator{origins: origins, cache: make(map[string]map[string]int)}
+	var issues ConfigErrors
+
+	if c.Filter.EntLimit < 0 {
+		issues = append(issues, loc.issue("filter.ent_limit", "must not be negative, got %v", c.Filter.EntLimit))
+	}
+	if c.Filter.MaxFileSize < 0 {
+		issues = append(issues, loc.issue("filter.max_file_size", "must not be negative, got %v", c.Filter.MaxFileSize))
+	}
+	if c.Filter.DecodeDepth < 0 {
+		issues = append(issues, loc.issue("filter.decode_depth", "must not be negative, got %d", c.Filter.DecodeDepth))
+	}
+	archiveLimits := []struct {
+		key   string
+		value int64
+	}{
+		{"archive.max_depth", int64(c.Archive.MaxDepth)},
+		{"archive.max_total_size", int64(c.Archive.MaxTotalSize)},
+		{"archive.max_ratio", c.Archive.MaxRatio},
+		{"archive.max_entries", int64(c.Archive.MaxEntries)},
+	}
+	for _, limit := range archiveLimits {
+		if limit.value < 0 {
+			issues = append(issues, loc.issue(limit.key, "must not be negative, got %d", limit.value))
+		}
+	}
+	if mode := core.BinaryMode(c.Binary); mode != "" && mode != core.BinarySkip && mode != core.BinaryStrings {
+		issues = append(issues, loc.issue("binary", "unsupported mode %q, expected skip or strings", c.Binary))
+	}
+	if _, err := core.ParseSeverity(c.FailOn); err != nil && c.FailOn != "" {
+		issues = append(issues, loc.issue("fail_on", "%v", err))
+	}
+	if c.MinConfidence < 0 || c.MinConfidence > 1 {
+		issues = append(issues, loc.issue("min_confidence", "must be between 0 and 1, got %v", c.MinConfidence))
+	}
+	switch core.PlaceholderMode(c.Placeholders.Mode) {
+	case "", core.PlaceholderDrop, core.PlaceholderAnnotate, core.PlaceholderOff:
+	default:
+		issues = append(issues, loc.issue("placeholders.mode", "unsupported mode %q, expected drop, annotate or off", c.Placeholders.Mode))
+	}
+	for _, name := range c.Placeholders.Disabled {
+		if !slices.Contains(core.PlaceholderChecks, name) {
+			issues = append(issues, loc.issue("placeholders.disabled", "unknown dictionary %q, expected one of %s", name, strings.Join(core.PlaceholderChecks, ", ")))
+		}
+	}
+	for _, format := range c.OutputFormat {
+		if _, ok := supportedOutputFormats[format]; !ok {
+			issues = append(issues, loc.issue("output_format", "unsupported format %q, expected one of json, txt, html", format))
+		}
+	}
+	if c.TreeSitterDir != "" {
+		if info, err := os.Stat(c.TreeSitterDir); err != nil {
+			issues = append(issues, loc.issue("treesitter_source", "%s does not exist", c.TreeSitterDir))
+		} else if !info.IsDir() {
+			issues = append(issues, loc.issue("treesitter_source", "%s is not a directory", c.TreeSitterDir))
+		}
+	}
+
+	names := make([]string, 0, len(c.Filter.TargetRegex))
+	for name := range c.Filter.TargetRegex {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		if _, err := c.Filter.TargetRegex[name].rule(name); err != nil {
+			issues = append(issues, loc.issueAt("filter.target_regex", "filter.target_regex."+name, "%v", err))
+		}
+		if clash := ruleNameClash(name); clash != "" {
+			issues = append(issues, loc.issueAt("filter.target_regex", "filter.target_regex."+name, "rule name %q %s", name, clash))
+		}
+	}
+
+	checkGlobs := func(key string, globs []string) {
+		for _, glob := range globs {
+			if !core.ValidGlob(glob) {
+				issues = append(issues, loc.issue(key, "invalid glob %q", glob))
+			}
+		}
+	}
+	checkGlobs("exclude", c.Exclude)
+	checkGlobs("include", c.Include)
+
+	rules := make(map[string]bool, len(builtinRules)+len(c.Filter.TargetRegex))
+	for _, name := range builtinRules {
+		rules[name] = true
+	}
+	for _, path := range c.ruleFilePaths(origins) {
+		loaded, err := core.LoadRuleFile(path)
+		if err != nil {
+			issues = append(issues, loc.issue("rule_files", "%v", err))
+			continue
+		}
+		for _, r := range loaded {
+			clash := ruleNameClash(r.ID)
+			if _, ok := c.Filter.TargetRegex[r.ID]; ok && clash == "" {
+				clash = "is also a target_regex name"
+			} else if rules[r.ID] && clash == "" {
+				clash = "is defined more than once"
+			}
+			if clash != "" {
+				issues = append(issues, loc.issue("rule_files", "%s: rule id %q %s", path, r.ID, clash))
+			}
+			rules[r.ID] = true
+		}
+	}
+	for name := range c.Filter.TargetRegex {
+		rules[name] = true
+	}
+	for i, o := range c.Overrides {
+		key := fmt.Sprintf("override[%d]", i)
+		if len(o.Paths) == 0 {
+			issues = append(issues, loc.issueAt("override", key, "paths must list at least one glob"))
+		}
+		for _, glob := range o.Paths {
+			if !core.ValidGlob(glob) {
+				issues = append(issues, loc.issueAt("override", key+".paths", "invalid glob %q", glob))
+			}
+		}
+		if o.EntLimit < 0 {
+			issues = append(issues, loc.issueAt("override", key+".ent_limit", "must not be negative, got %v", o.EntLimit))
+		}
+		if o.MaxFileSize < 0 {
+			issues = append(issues, loc.issueAt("override", key+".max_file_size", "must not be negative, got %v", o.MaxFileSize))
+		}
+		for _, name := range o.Enabled {
+			if !rules[name] {
+				issues = append(issues, loc.issueAt("override", key+".enabled", "unknown rule %q, expected %s, a target_regex name or a rule_files id", name, strings.Join(builtinRules, ", ")))
+			}
+		}
+		for _, name := range o.Disabled {
+			if !rules[name] {
+				issues = append(issues, loc.issueAt("override", key+".disabled", "unknown rule %q, expected %s, a target_regex name or a rule_files id", name, strings.Join(builtinRules, ", ")))
+			}
+		}
+	}
+	return issues
+}
+
+// ruleNameClash describes how a rule name collides with a built-in rule or a
+// payload key the scan sets itself, or returns "" when it does not
+func ruleNameClash(name string) string {
+	if slices.Contains(builtinRules, name) {
+		return "is the name of a built-in rule"
+	}
+	if core.IsReservedPayloadKey(name) {
+		return "is a payload key the scan sets itself"
+	}
+	return ""
+}
+
+// ValidateConfigFile decodes and validates a single config file
+func ValidateConfigFile(path string) ConfigErrors {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return ConfigErrors{{Source: path, Msg: err.Error()}}
+	}
+	cfg := DefaultConfig()
+	md, err := toml.Decode(string(data), cfg)
+	if err != nil {
+		return ConfigErrors{decodeIssue(path, err)}
+	}
+
+	origins := make(ConfigOrigins)
+	for _, key := range configKeys() {
+		origins[key] = originDefault
+	}
+	mergeDefined(cfg, cfg, md, path, origins)
+
+	issues := unknownKeys(path, string(data), md)
+	return append(issues, cfg.Validate(origins)...)
+}
@@ -2,6 +2,12 @@ package frontend
 
 import (
 	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
 
 	core "github.com/steverahardjo/gitaegis/core"
 )
@@ -15,11 +21,33 @@ type RuntimeValue struct {
 	UseGitignore   bool
 	GitDiffScan		bool
 	MaxFileSize    int64
+	DecodeDepth    int
 	TreeSitterPath string
-	Filters        core.LineFilter
+	Filters        core.Filter
 	GlobalResult   core.ScanResult
+	TargetRegex    map[string]core.Rule
+	Rules          []core.Rule
+	Overrides      []Override
+	Placeholders   core.Placeholders
+	FailOn         core.Severity
+	MinConfidence  float64
 }
 
+// defaultMaxFileSize is the size above which files are skipped unless configured
+const defaultMaxFileSize = 500 * kilobyte
+
+// defaultDecodeDepth is how many layers of base64, hex or URL encoding are decoded
+const defaultDecodeDepth = 2
+
+// Built-in rules, named in the enabled and disabled lists of an override
+const (
+	RuleEntropy = "entropy"
+	RuleURI     = "uri"
+)
+
+// builtinRules are the rules every scan has besides its target regexes
+var builtinRules = []string{RuleEntropy, RuleURI}
+
 // NewRuntimeConfig initializes a RuntimeValue with default values
 func NewRuntimeConfig() *RuntimeValue {
 	rv := &RuntimeValue{
@@ -28,7 +56,10 @@ func NewRuntimeConfig() *RuntimeValue {
 		LoggingEnabled: true,
 		GitIntegration: false,
 		UseGitignore:   true,
-		MaxFileSize:    500,
+		MaxFileSize:    int64(defaultMaxFileSize),
+		DecodeDepth:    defaultDecodeDepth,
+		Placeholders:   core.Placeholders{Mode: core.PlaceholderDrop},
+		FailOn:         core.SeverityLow,
 	}
 	rv.Result.Init()
 	return rv
@@ -71,22 +102,262 @@ func (rv *RuntimeValue) SetEntropyLimit(limit float64) {
 	rv.EntropyLimit = limit
 }
 
-// SetMaxFileSize updates the maximum file size limit (in KB)
+// SetMaxFileSize updates the maximum file size limit (in bytes)
 func (rv *RuntimeValue) SetMaxFileSize(size int64) {
 	if size <= 0 {
-		size = 500
+		size = int64(defaultMaxFileSize)
 	}
 	rv.MaxFileSize = size
 }
 
-// SetFilters builds and applies regex + entropy filters
+// SetDecodeDepth sets how many layers of encoding are decoded and rescanned, 0 disables decoding
+func (rv *RuntimeValue) SetDecodeDepth(depth int) {
+	if depth < 0 {
+		depth = 0
+	}
+	rv.DecodeDepth = depth
+}
+
+// SetPlaceholders configures how findings that look like placeholders are
+// handled, dropping them unless a mode is given
+func (rv *RuntimeValue) SetPlaceholders(p core.Placeholders) {
+	if p.Mode == "" {
+		p.Mode = core.PlaceholderDrop
+	}
+	rv.Placeholders = p
+}
+
+// SetFailOn sets the least severe finding that makes a scan fail
+func (rv *RuntimeValue) SetFailOn(severity core.Severity) {
+	rv.FailOn = severity
+}
+
+// SetMinConfidence hides findings scored below confidence, between 0 and 1
+func (rv *RuntimeValue) SetMinConfidence(confidence float64) {
+	rv.MinConfidence = min(max(confidence, 0), 1)
+}
+
+// placeholders returns the placeholder settings with the payload keys of
+// every named rule, whose matches are exempt from the uuid and hash checks
+func (rv *RuntimeValue) placeholders() core.Placeholders {
+	p := rv.Placeholders
+	p.Rules = make(map[string]bool)
+	for key := range rv.scoring().Rules {
+		p.Rules[key] = true
+	}
+	return p
+}
+
+// scoring returns how findings are scored: the uri rule reports at high
+// severity, target regexes and rule file rules at their own and
+// entropy-only findings at medium
+func (rv *RuntimeValue) scoring() core.Scoring {
+	rules := map[string]core.Severity{core.URIPayloadKey: core.SeverityHigh}
+	for name, r := range rv.TargetRegex {
+		rules[name] = r.Severity
+	}
+	for _, r := range rv.Rules {
+		rules[r.ID] = r.Severity
+	}
+	return core.Scoring{Rules: rules, MinConfidence: rv.MinConfidence}
+}
+
+// SetFilters builds and applies regex + entropy filters, each regex
+// reporting its whole match
 func (rv *RuntimeValue) SetFilters(regexes map[string]string) {
-	var filters []core.LineFilter
-	for k, v := range regexes {
-		filters = append(filters, core.AddTargetRegexPattern(k, v))
+	entries := make(map[string]TargetRegex, len(regexes))
+	for name, pattern := range regexes {
+		entries[name] = TargetRegex{Regex: pattern}
 	}
-	filters = append(filters, core.BasicFilter())
-	filters = append(filters, core.EntropyFilter(rv.EntropyLimit))
-	rv.Filters = core.AllFilters(filters...)
+	rv.SetTargetRegex(entries)
+}
+
+// SetTargetRegex builds and applies the filters of target_regex entries
+func (rv *RuntimeValue) SetTargetRegex(entries map[string]TargetRegex) {
+	rv.TargetRegex = targetRules(entries)
+	rv.Filters = rv.buildFilter(rv.EntropyLimit, nil)
 	fmt.Println("[Config] Filters initialized")
 }
+
+// SetRules installs the rules loaded from rule files
+func (rv *RuntimeValue) SetRules(rules []core.Rule) {
+	rv.Rules = rules
+	rv.Filters = rv.buildFilter(rv.EntropyLimit, nil)
+}
+
+// SetExclusions adds exclude and include globs to the scan, recording their source
+func (rv *RuntimeValue) SetExclusions(exclude []string, include []string, source string) {
+	for _, glob := range exclude {
+		rv.Result.AddExemptFrom(glob, source)
+	}
+	for _, glob := range include {
+		rv.Result.AddInclude(glob, source)
+	}
+}
+
+// SetOverrides installs the path-scoped overrides applied per file
+func (rv *RuntimeValue) SetOverrides(overrides []Override) {
+	rv.Overrides = overrides
+}
+
+// RuleNames lists the rules overrides can enable or disable
+func (rv *RuntimeValue) RuleNames() []string {
+	names := append([]string(nil), builtinRules...)
+	for name := range rv.TargetRegex {
+		names = append(names, name)
+	}
+	for _, r := range rv.Rules {
+		names = append(names, r.ID)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// buildFilter matches any target regex or rule file rule, the entropy limit
+// or a credential in a URI, in tokens as they are or once decoded. The
+// regexes share one keyword prefilter. A nil rules set enables every rule,
+// otherwise only the rules set to true are used.
+func (rv *RuntimeValue) buildFilter(limit float64, rules map[string]bool) core.Filter {
+	names := make([]string, 0, len(rv.TargetRegex))
+	for name := range rv.TargetRegex {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	var regexRules []core.Rule
+	for _, name := range names {
+		if rules == nil || rules[name] {
+			regexRules = append(regexRules, rv.TargetRegex[name])
+		}
+	}
+	for _, r := range rv.Rules {
+		if rules == nil || rules[r.ID] {
+			regexRules = append(regexRules, r)
+		}
+	}
+
+	filters := []core.Filter{core.RuleSet(regexRules)}
+	if rules == nil || rules[RuleEntropy] {
+		filters = append(filters, core.EntropyFilter(limit))
+	}
+	if rules == nil || rules[RuleURI] {
+		filters = append(filters, core.URIFilter())
+	}
+	direct := core.AnyOf(filters...)
+	// Decoded findings come first so the payload of a direct match wins.
+	// Decoded tokens have no source line of their own.
+	return core.AnyOf(core.DecodeFilter(core.TextFilter(direct), rv.DecodeDepth), direct)
+}
+
+// overrideSettings merges the overrides at the given indexes over the
+// defaults, leaving out the skipped rules
+func (rv *RuntimeValue) overrideSettings(matched []int, skipped []string) *core.FileSettings {
+	limit := rv.EntropyLimit
+	maxSize := rv.MaxFileSize
+	var rules map[string]bool
+	for _, i := range matched {
+		o := rv.Overrides[i]
+		if o.EntLimit > 0 {
+			limit = o.EntLimit
+		}
+		if o.MaxFileSize > 0 {
+			maxSize = int64(o.MaxFileSize)
+		}
+		if len(o.Enabled) > 0 {
+			rules = make(map[string]bool, len(o.Enabled))
+			for _, name := range o.Enabled {
+				rules[name] = true
+			}
+		}
+		if len(o.Disabled) > 0 && rules == nil {
+			rules = make(map[string]bool)
+			for _, name := range rv.RuleNames() {
+				rules[name] = true
+			}
+		}
+		for _, name := range o.Disabled {
+			delete(rules, name)
+		}
+	}
+	if len(skipped) > 0 && rules == nil {
+		rules = make(map[string]bool)
+		for _, name := range rv.RuleNames() {
+			rules[name] = true
+		}
+	}
+	for _, name := range skipped {
+		delete(rules, name)
+	}
+	return &core.FileSettings{Filter: rv.buildFilter(limit, rules), MaxFileSize: maxSize}
+}
+
+// Resolver returns the per-file settings of the overrides and of rules
+// limited to some paths, with globs and rule paths matched against paths
+// relative to root. It returns nil when neither depends on the file.
+func (rv *RuntimeValue) Resolver(root string) core.FileResolver {
+	var scoped []core.Rule
+	for _, r := range rv.Rules {
+		if r.PathScoped() {
+			scoped = append(scoped, r)
+		}
+	}
+	if len(rv.Overrides) == 0 && len(scoped) == 0 {
+		return nil
+	}
+	var mu sync.Mutex
+	cache := make(map[string]*core.FileSettings)
+	return func(path string) *core.FileSettings {
+		abs, err := filepath.Abs(path)
+		if err != nil {
+			return nil
+		}
+		rel, err := filepath.Rel(root, abs)
+		if err != nil || strings.HasPrefix(rel, "..") {
+			return nil
+		}
+
+		var matched []int
+		var key strings.Builder
+		for i, o := range rv.Overrides {
+			for _, glob := range o.Paths {
+				if core.MatchGlob(glob, rel) {
+					matched = append(matched, i)
+					key.WriteString(strconv.Itoa(i) + ",")
+					break
+				}
+			}
+		}
+		var skipped []string
+		key.WriteString("|")
+		for _, r := range scoped {
+			if !r.AppliesTo(filepath.ToSlash(rel)) {
+				skipped = append(skipped, r.ID)
+				key.WriteString(r.ID + ",")
+			}
+		}
+		if len(matched) == 0 && len(skipped) == 0 {
+			return nil
+		}
+
+		mu.Lock()
+		defer mu.Unlock()
+		settings, ok := cache[key.String()]
+		if !ok {
+			settings = rv.overrideSettings(matched, skipped)
+			cache[key.String()] = settings
+		}
+		return settings
+	}
+}
+
+// scanRoot is the directory override and exclusion globs are relative to:
+// the repository root, or the working directory outside a repository
+func scanRoot() string {
+	wd, err := os.Getwd()
+	if err != nil {
+		return "."
+	}
+	if root, err := core.FindRepoRoot(wd); err == nil {
+		return root
+	}
+	return wd
+}
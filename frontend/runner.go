@@ -1,11 +1,14 @@
 package frontend
 
 import (
+	"bufio"
 	"fmt"
+	"io"
 	"path/filepath"
 	"time"
 	"os/exec"
 	"os"
+	"sort"
 	"strings"
 	core "github.com/steverahardjo/gitaegis/core"
 )
@@ -31,8 +34,8 @@ func (rv *RuntimeValue) Add(repoPath string, paths ...string) error {
 
 // Scan scans the provided project paths using the RuntimeValue's configuration.
 // PrettyPrint() the result into the console
-// Returns (true, nil) if secrets were found, (false, nil) when none found,
-// or (false, err) on error.
+// Returns (true, nil) if findings at or above the fail_on severity were found,
+// (false, nil) when none were, or (false, err) on error.
 func (rv *RuntimeValue) Scan(projectPaths ...string) (bool, error) {
 	if len(projectPaths) == 0 {
 		projectPaths = []string{"."}
@@ -44,12 +47,13 @@ func (rv *RuntimeValue) Scan(projectPaths ...string) (bool, error) {
 
 	fmt.Println("Scanning paths:", projectPaths)
 	time.Sleep(1 * time.Second)
-	rv.SetTreeSitterPath("/home/holyknight101/.private/helix/runtime/grammars")
 	core.IntegrateTreeSitter(rv.TreeSitterPath)
-	filter := core.AnyFilters(
-		rv.Filters,
-		core.EntropyFilter(rv.EntropyLimit),
-	)
+	filter := rv.ActiveFilter()
+	root := scanRoot()
+	if err := rv.Result.SetRoot(root); err != nil {
+		return false, err
+	}
+	rv.Result.SetResolver(rv.Resolver(root))
 	for _, path := range projectPaths {
 		if rv.GitDiffScan {
 			
@@ -63,6 +67,29 @@ func (rv *RuntimeValue) Scan(projectPaths ...string) (bool, error) {
 			}
 		}
 	}
+	for _, partial := range rv.Result.Truncated() {
+		fmt.Fprintf(os.Stderr, "warning: only part of %s was scanned\n", partial)
+	}
+	if n := rv.Result.SuppressPlaceholders(rv.placeholders()); n > 0 {
+		if rv.Placeholders.Mode == core.PlaceholderAnnotate {
+			fmt.Printf("%d finding(s) annotated as placeholders\n", n)
+		} else {
+			fmt.Printf("%d finding(s) suppressed as placeholders\n", n)
+		}
+	}
+	if n := rv.Result.ScoreFindings(rv.scoring()); n > 0 {
+		fmt.Printf("%d finding(s) below the minimum confidence of %v hidden\n", n, rv.MinConfidence)
+	}
+	saveRoot, err := filepath.Abs(".")
+	if err != nil {
+		return false, fmt.Errorf("[runner.Scan] failed to resolve save path: %w", err)
+	}
+	if dropped, err := rv.Result.ApplyBaseline(saveRoot); err != nil {
+		return false, fmt.Errorf("failed to apply baseline: %w", err)
+	} else if dropped > 0 {
+		fmt.Printf("%d finding(s) suppressed by baseline or inline allow\n", dropped)
+	}
+
 	res := rv.Result.IsFilenameMapEmpty()
 	if res {
 		fmt.Println("Nothing is found in the fileMap")
@@ -70,36 +97,220 @@ func (rv *RuntimeValue) Scan(projectPaths ...string) (bool, error) {
 	}
 	rv.Result.PrettyPrintResults()
 
-	saveRoot, err := filepath.Abs(".")
-	if err != nil {
-		return true, fmt.Errorf("[runner.Scan] failed to resolve save path: %w", err)
-	}
-
 	if rv.LoggingEnabled && !res {
 		if err := rv.Result.SaveFilenameMap(saveRoot); err != nil {
 			return true, fmt.Errorf("failed to save scan results: %w", err)
 		}
 	}
 
-	return true, nil
+	return rv.Result.HasSeverity(rv.FailOn), nil
+}
+
+// ActiveFilter returns the filter chain used for files no override matches
+func (rv *RuntimeValue) ActiveFilter() core.Filter {
+	return rv.buildFilter(rv.EntropyLimit, nil)
+}
+
+// RunFilter runs one side of the git clean/smudge filter driver, streaming
+// stdin to out. path is the worktree path git passes as %f.
+func (rv *RuntimeValue) RunFilter(mode string, path string, out io.Writer) error {
+	root, err := os.Getwd()
+	if err != nil {
+		return fmt.Errorf("failed to get working directory: %w", err)
+	}
+	store, err := core.OpenSecretStore(root)
+	if err != nil {
+		return fmt.Errorf("unable to open secret store: %w", err)
+	}
+
+	if err := rv.Result.SetRoot(scanRoot()); err != nil {
+		return err
+	}
+
+	switch mode {
+	case "clean":
+		filter := rv.ActiveFilter()
+		if resolve := rv.Resolver(scanRoot()); resolve != nil && path != "" {
+			if settings := resolve(path); settings != nil {
+				filter = settings.Filter
+			}
+		}
+		if path != "" && rv.Result.IsExempt(path) {
+			filter = nil
+		}
+		return core.CleanStream(os.Stdin, out, filter, store)
+	case "smudge":
+		return core.SmudgeStream(os.Stdin, out, store)
+	default:
+		return fmt.Errorf("unknown filter mode %q, expected clean or smudge", mode)
+	}
 }
 
-// RunObfuscate loads obfuscation ruleGetFilenames from the current working directory
-// and performs the obfuscation via core.LoadObfuscation.
+// RunObfuscate loads the previous scan result from the current working directory
+// and replaces the detected secrets via core.LoadObfuscation.
 func RunObfuscate() error {
-	/*
-		root, err := os.Getwd()
+	root, err := os.Getwd()
+	if err != nil {
+		return fmt.Errorf("failed to get working directory: %w", err)
+	}
+
+	journal, err := core.LoadObfuscation(root)
+	if err != nil {
+		return fmt.Errorf("failed to obfuscate secrets: %w", err)
+	}
+
+	for _, f := range journal.Files {
+		fmt.Printf("Obfuscated %d secret(s) in %s\n", len(f.Entries), f.Path)
+	}
+	journalPath, _ := core.JournalPath(root)
+	fmt.Println("Secrets obfuscated successfully. Restore journal:", journalPath)
+	return nil
+}
+
+// RunRestore restores the secrets obfuscated in the current working directory.
+func RunRestore() error {
+	root, err := os.Getwd()
+	if err != nil {
+		return fmt.Errorf("failed to get working directory: %w", err)
+	}
+
+	restored, refused, err := core.UndoObfuscate(root)
+	for _, f := range restored {
+		fmt.Println("Restored", f)
+	}
+	for _, f := range refused {
+		fmt.Println("Refused to restore (changed since obfuscation):", f)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to restore secrets: %w", err)
+	}
+	if len(refused) > 0 {
+		return fmt.Errorf("%d file(s) could not be restored", len(refused))
+	}
+	return nil
+}
+
+// RunIgnore adds the files of the previous scan to the repository's .gitignore
+func RunIgnore(dryRun bool, untrack bool) error {
+	blob, err := core.LoadFilenameMap(".")
+	if err != nil {
+		return fmt.Errorf("unable to load scan results: %w", err)
+	}
+	root, err := core.FindRepoRoot(".")
+	if err != nil {
+		root = "."
+	}
+
+	update, err := core.UpdateGitignore(root, blob, dryRun, untrack)
+	if err != nil {
+		return fmt.Errorf("failed to update .gitignore: %w", err)
+	}
+
+	skipped := make([]string, 0, len(update.Skipped))
+	for entry := range update.Skipped {
+		skipped = append(skipped, entry)
+	}
+	sort.Strings(skipped)
+	for _, entry := range skipped {
+		fmt.Printf("Skipped %s (%s)\n", entry, update.Skipped[entry])
+	}
+
+	if dryRun {
+		if len(update.Added) == 0 {
+			fmt.Println("Dry run: .gitignore is already up to date.")
+			return nil
+		}
+		fmt.Print(core.UnifiedDiff(".gitignore", update.Before, update.After))
+		if untrack {
+			fmt.Println("Dry run: tracked files would also be removed from the index.")
+		}
+		return nil
+	}
+
+	for _, entry := range update.Added {
+		fmt.Printf("Added %s to .gitignore\n", entry)
+	}
+	for _, p := range update.Untracked {
+		fmt.Printf("Removed %s from the index\n", p)
+	}
+	if len(update.Added) == 0 {
+		fmt.Println(".gitignore is already up to date.")
+	} else {
+		fmt.Println("Updated .gitignore successfully.")
+	}
+	return nil
+}
+
+// RunEnvFix moves the secrets of the previous scan into the .env file of the
+// current working directory, showing a diff preview before writing.
+func RunEnvFix(assumeYes bool, in io.Reader) error {
+	root, err := os.Getwd()
+	if err != nil {
+		return fmt.Errorf("failed to get working directory: %w", err)
+	}
+	blob, err := core.LoadFilenameMap(root)
+	if err != nil {
+		return fmt.Errorf("unable to load scan results: %w", err)
+	}
+
+	envPath := filepath.Join(root, ".env")
+	taken, envBefore, err := core.LoadEnvNames(envPath)
+	if err != nil {
+		return fmt.Errorf("unable to read .env: %w", err)
+	}
+
+	filenames := make([]string, 0, len(blob))
+	for filename := range blob {
+		filenames = append(filenames, filename)
+	}
+	sort.Strings(filenames)
+
+	var fixes []*core.EnvFix
+	var vars []core.EnvVar
+	for _, filename := range filenames {
+		if !core.SupportsEnvFix(filename) {
+			continue
+		}
+		fix, err := core.PlanEnvFix(filename, blob[filename], taken)
 		if err != nil {
-			return fmt.Errorf("failed to get working directory: %w", err)
+			fmt.Printf("Skipping %s: %v\n", filename, err)
+			continue
 		}
+		if fix == nil {
+			continue
+		}
+		fixes = append(fixes, fix)
+		vars = append(vars, fix.Vars...)
+	}
+	if len(fixes) == 0 {
+		fmt.Println("No string literal secrets could be moved to .env.")
+		return nil
+	}
 
-		if err := core.LoadObfuscation(root); err != nil {
-			return fmt.Errorf("failed to obfuscate secrets: %w", err)
+	envAfter := core.AppendEnvVars(envBefore, vars)
+	for _, fix := range fixes {
+		rel, err := filepath.Rel(root, fix.Path)
+		if err != nil {
+			rel = fix.Path
 		}
+		fmt.Print(core.UnifiedDiff(rel, fix.Original, fix.Updated))
+	}
+	fmt.Print(core.UnifiedDiff(".env", envBefore, envAfter))
 
-		fmt.Println("Secrets obfuscated successfully.")
-		return nil
-	*/
+	if !assumeYes {
+		fmt.Print("\nApply these changes? [y/N] ")
+		answer, _ := bufio.NewReader(in).ReadString('\n')
+		answer = strings.ToLower(strings.TrimSpace(answer))
+		if answer != "y" && answer != "yes" {
+			fmt.Println("Aborted, nothing written.")
+			return nil
+		}
+	}
+
+	if err := core.WriteEnvFixes(root, fixes, envAfter); err != nil {
+		return err
+	}
+	fmt.Printf("Moved %d secret(s) from %d file(s) into .env\n", len(vars), len(fixes))
 	return nil
 }
 
@@ -0,0 +1,121 @@
+package frontend
+
+import (
+	"fmt"
+	"log"
+	"regexp"
+	"slices"
+	"strings"
+
+	core "github.com/steverahardjo/gitaegis/core"
+)
+
+// TargetRegex is a target_regex entry. In config files it is either a bare
+// pattern, reporting the whole match, or a table with the keys below.
+type TargetRegex struct {
+	Regex string `toml:"regex"`
+	// SecretGroup is the capture group reported and entropy-checked, 0 for
+	// the whole match
+	SecretGroup int      `toml:"secret_group"`
+	MinEntropy  float64  `toml:"min_entropy"`
+	Keywords    []string `toml:"keywords"`
+	Severity    string   `toml:"severity"`
+}
+
+// targetRegexKeys are the keys of the table form, in the order they are documented
+var targetRegexKeys = []string{"regex", "secret_group", "min_entropy", "keywords", "severity"}
+
+// UnmarshalTOML accepts a pattern string or a table of TargetRegex keys
+func (t *TargetRegex) UnmarshalTOML(v any) error {
+	switch v := v.(type) {
+	case string:
+		*t = TargetRegex{Regex: v}
+		return nil
+	case map[string]any:
+		*t = TargetRegex{}
+		for key, value := range v {
+			var ok bool
+			switch key {
+			case "regex":
+				t.Regex, ok = value.(string)
+			case "secret_group":
+				var n int64
+				n, ok = value.(int64)
+				t.SecretGroup = int(n)
+			case "min_entropy":
+				switch n := value.(type) {
+				case float64:
+					t.MinEntropy, ok = n, true
+				case int64:
+					t.MinEntropy, ok = float64(n), true
+				}
+			case "keywords":
+				var items []any
+				items, ok = value.([]any)
+				for _, item := range items {
+					word, isString := item.(string)
+					if !isString {
+						ok = false
+						break
+					}
+					t.Keywords = append(t.Keywords, word)
+				}
+			case "severity":
+				t.Severity, ok = value.(string)
+			default:
+				return fmt.Errorf("unknown target_regex key %q, expected one of %s", key, strings.Join(targetRegexKeys, ", "))
+			}
+			if !ok {
+				return fmt.Errorf("invalid target_regex %s %v", key, value)
+			}
+		}
+		return nil
+	}
+	return fmt.Errorf("invalid target_regex %v, expected a pattern or a table with a regex key", v)
+}
+
+// rule compiles the entry into the rule reported under name. Entries
+// without a severity are high.
+func (t TargetRegex) rule(name string) (core.Rule, error) {
+	if t.Regex == "" {
+		return core.Rule{}, fmt.Errorf("missing regex")
+	}
+	re, err := regexp.Compile(t.Regex)
+	if err != nil {
+		return core.Rule{}, fmt.Errorf("%s", regexIssue(t.Regex, err))
+	}
+	if t.SecretGroup < 0 || t.SecretGroup > re.NumSubexp() {
+		return core.Rule{}, fmt.Errorf("secret_group %d does not exist, the regex has %d groups", t.SecretGroup, re.NumSubexp())
+	}
+	if t.MinEntropy < 0 {
+		return core.Rule{}, fmt.Errorf("min_entropy must not be negative, got %v", t.MinEntropy)
+	}
+	severity := core.SeverityHigh
+	if t.Severity != "" {
+		if severity, err = core.ParseSeverity(t.Severity); err != nil {
+			return core.Rule{}, err
+		}
+	}
+	return core.Rule{
+		ID:          name,
+		Regex:       re,
+		SecretGroup: t.SecretGroup,
+		Entropy:     t.MinEntropy,
+		Keywords:    slices.Clone(t.Keywords),
+		Severity:    severity,
+	}, nil
+}
+
+// targetRules compiles target_regex entries, leaving out invalid ones
+func targetRules(entries map[string]TargetRegex) map[string]core.Rule {
+	rules := make(map[string]core.Rule, len(entries))
+	for name, t := range entries {
+		r, err := t.rule(name)
+		if err != nil {
+			log.Printf("[Config] skipping target_regex %s: %v", name, err)
+			continue
+		}
+		rules[name] = r
+	}
+	return rules
+}
@@ -6,6 +6,8 @@ import (
 	"os/exec"
 	"path/filepath"
 	"strings"
+
+	core "github.com/steverahardjo/gitaegis/core"
 )
 
 // config to add bash script to run Scan before doing add
@@ -93,3 +95,37 @@ fi
 	fmt.Println("gitaegis pre-commit hook installed successfully.")
 	return nil
 }
+
+// gitattributes line routing every file through the gitaegis filter driver
+const filterAttribute = "* filter=gitaegis"
+
+// enable gitaegis as a clean/smudge filter so staged content never holds a raw secret
+func GitFilterInit(root string) error {
+	if err := core.RegisterFilterDriver(root); err != nil {
+		return fmt.Errorf("failed to register filter driver: %v", err)
+	}
+
+	attrPath := filepath.Join(root, ".gitattributes")
+	data, err := os.ReadFile(attrPath)
+	if err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("failed to read .gitattributes: %v", err)
+	}
+	for _, line := range strings.Split(string(data), "\n") {
+		if strings.TrimSpace(line) == filterAttribute {
+			fmt.Println("gitaegis filter already present in .gitattributes.")
+			return nil
+		}
+	}
+
+	content := string(data)
+	if len(content) > 0 && !strings.HasSuffix(content, "\n") {
+		content += "\n"
+	}
+	content += "# gitaegis clean/smudge filter\n" + filterAttribute + "\n"
+	if err := os.WriteFile(attrPath, []byte(content), 0644); err != nil {
+		return fmt.Errorf("failed to write .gitattributes: %v", err)
+	}
+
+	fmt.Println("gitaegis clean/smudge filter installed successfully.")
+	return nil
+}
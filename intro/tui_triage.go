@@ -0,0 +1,316 @@
+package intro
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	tea "github.com/charmbracelet/bubbletea"
+	core "github.com/steverahardjo/gitaegis/core"
+)
+
+const (
+	red    = "\033[31m"
+	green  = "\033[32m"
+	yellow = "\033[33m"
+	faint  = "\033[2m"
+	invert = "\033[7m"
+	reset  = "\033[0m"
+)
+
+// contextRadius is the number of source lines shown around a finding
+const contextRadius = 3
+
+// decisionLabels are the short markers shown in the findings list
+var decisionLabels = map[string]string{
+	core.DecisionFalsePositive: green + "[fp]" + reset,
+	core.DecisionAllowed:       green + "[ok]" + reset,
+	core.DecisionIgnored:       yellow + "[ig]" + reset,
+	core.DecisionObfuscated:    yellow + "[ob]" + reset,
+}
+
+type triageModel struct {
+	root      string
+	findings  []core.Finding
+	baseline  *core.Baseline
+	decisions core.Decisions
+	cursor    int
+	offset    int
+	height    int
+	status    string
+}
+
+// RunTriage opens the interactive triage UI over the findings of the last scan in root
+func RunTriage(root string) error {
+	blob, err := core.LoadFilenameMap(root)
+	if err != nil {
+		return fmt.Errorf("unable to load scan results, run 'gitaegis scan -l' first: %w", err)
+	}
+	findings := core.Findings(blob)
+	if len(findings) == 0 {
+		fmt.Println("No findings to triage.")
+		return nil
+	}
+	baseline, err := core.LoadBaseline(root)
+	if err != nil {
+		return err
+	}
+	decisions, err := core.LoadDecisions(root)
+	if err != nil {
+		return err
+	}
+
+	m := &triageModel{
+		root:      root,
+		findings:  findings,
+		baseline:  baseline,
+		decisions: decisions,
+		height:    24,
+	}
+	_, err = tea.NewProgram(m, tea.WithAltScreen()).Run()
+	return err
+}
+
+func (m *triageModel) Init() tea.Cmd { return nil }
+
+// listHeight is the number of findings visible at once
+func (m *triageModel) listHeight() int {
+	h := m.height - (2*contextRadius + 12)
+	if h < 3 {
+		h = 3
+	}
+	return h
+}
+
+func (m *triageModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
+	switch msg := msg.(type) {
+	case tea.WindowSizeMsg:
+		m.height = msg.Height
+	case tea.KeyMsg:
+		m.status = ""
+		switch msg.String() {
+		case "q", "ctrl+c", "esc":
+			return m, tea.Quit
+		case "up", "k":
+			if m.cursor > 0 {
+				m.cursor--
+			}
+		case "down", "j":
+			if m.cursor < len(m.findings)-1 {
+				m.cursor++
+			}
+		case "g", "home":
+			m.cursor = 0
+		case "G", "end":
+			m.cursor = len(m.findings) - 1
+		case "f":
+			m.markFalsePositive()
+		case "a":
+			m.allowInline()
+		case "i":
+			m.ignoreFile()
+		case "o":
+			m.obfuscateFile()
+		case "u":
+			m.undo()
+		}
+	}
+
+	if m.cursor < m.offset {
+		m.offset = m.cursor
+	}
+	if m.cursor >= m.offset+m.listHeight() {
+		m.offset = m.cursor - m.listHeight() + 1
+	}
+	return m, nil
+}
+
+func (m *triageModel) current() core.Finding { return m.findings[m.cursor] }
+
+// decide records a decision for a finding and persists all decisions
+func (m *triageModel) decide(f core.Finding, decision string) error {
+	m.decisions[f.Fingerprint(m.root)] = decision
+	return m.decisions.Save(m.root)
+}
+
+func (m *triageModel) markFalsePositive() {
+	f := m.current()
+	m.baseline.Add(m.root, f, "false positive")
+	if err := m.baseline.Save(m.root); err != nil {
+		m.status = red + "failed to save baseline: " + err.Error() + reset
+		return
+	}
+	if err := m.decide(f, core.DecisionFalsePositive); err != nil {
+		m.status = red + err.Error() + reset
+		return
+	}
+	m.status = "Added to baseline."
+}
+
+func (m *triageModel) allowInline() {
+	f := m.current()
+	if !core.CanAllowInline(f.Path) {
+		m.status = yellow + "No comment syntax for this file, use f to baseline it instead." + reset
+		return
+	}
+	if err := core.AllowInline(f); err != nil {
+		m.status = red + "failed to add allow comment: " + err.Error() + reset
+		return
+	}
+	if err := m.decide(f, core.DecisionAllowed); err != nil {
+		m.status = red + err.Error() + reset
+		return
+	}
+	m.status = "Inline " + core.AllowMarker + " comment added."
+}
+
+func (m *triageModel) ignoreFile() {
+	f := m.current()
+	if err := core.AddToGitignore(m.root, f.Path); err != nil {
+		m.status = red + "failed to update .gitignore: " + err.Error() + reset
+		return
+	}
+	for _, other := range m.findings {
+		if other.Path == f.Path {
+			m.decisions[other.Fingerprint(m.root)] = core.DecisionIgnored
+		}
+	}
+	if err := m.decisions.Save(m.root); err != nil {
+		m.status = red + err.Error() + reset
+		return
+	}
+	m.status = "Added " + m.relPath(f.Path) + " to .gitignore."
+}
+
+// obfuscateFile obfuscates every undecided finding of the current file
+func (m *triageModel) obfuscateFile() {
+	f := m.current()
+	var line core.CodeLine
+	var picked []core.Finding
+	for _, other := range m.findings {
+		if other.Path != f.Path {
+			continue
+		}
+		if _, decided := m.decisions[other.Fingerprint(m.root)]; decided {
+			continue
+		}
+		line.Lines = append(line.Lines, other.Text)
+		line.Indexes = append(line.Indexes, other.Line)
+		line.Columns = append(line.Columns, other.Column)
+		picked = append(picked, other)
+	}
+	if len(picked) == 0 {
+		m.status = yellow + "Nothing left to obfuscate in this file." + reset
+		return
+	}
+	if _, err := core.Obfuscate(m.root, map[string]core.CodeLine{f.Path: line}); err != nil {
+		m.status = red + "failed to obfuscate: " + err.Error() + reset
+		return
+	}
+	for _, p := range picked {
+		m.decisions[p.Fingerprint(m.root)] = core.DecisionObfuscated
+	}
+	if err := m.decisions.Save(m.root); err != nil {
+		m.status = red + err.Error() + reset
+		return
+	}
+	m.status = fmt.Sprintf("Obfuscated %d secret(s), run 'gitaegis restore' to undo.", len(picked))
+}
+
+// undo forgets the decision of the current finding, dropping its baseline entry
+func (m *triageModel) undo() {
+	f := m.current()
+	fp := f.Fingerprint(m.root)
+	switch m.decisions[fp] {
+	case "":
+		m.status = "No decision to undo."
+		return
+	case core.DecisionFalsePositive:
+		delete(m.baseline.Entries, fp)
+		if err := m.baseline.Save(m.root); err != nil {
+			m.status = red + err.Error() + reset
+			return
+		}
+	case core.DecisionObfuscated:
+		m.status = yellow + "Use 'gitaegis restore' to undo obfuscation." + reset
+		return
+	case core.DecisionAllowed:
+		m.status = yellow + "Remove the " + core.AllowMarker + " comment by hand to undo." + reset
+		return
+	case core.DecisionIgnored:
+		m.status = yellow + "Remove " + m.relPath(f.Path) + " from .gitignore by hand to undo." + reset
+		return
+	}
+	delete(m.decisions, fp)
+	if err := m.decisions.Save(m.root); err != nil {
+		m.status = red + err.Error() + reset
+		return
+	}
+	m.status = "Decision cleared."
+}
+
+func (m *triageModel) relPath(path string) string {
+	if rel, err := filepath.Rel(m.root, path); err == nil && !strings.HasPrefix(rel, "..") {
+		return rel
+	}
+	return path
+}
+
+func truncate(s string, n int) string {
+	r := []rune(s)
+	if len(r) <= n {
+		return s
+	}
+	return string(r[:n-1]) + "…"
+}
+
+func (m *triageModel) View() string {
+	var b strings.Builder
+
+	b.WriteString(yellow + "gitaegis triage" + reset)
+	fmt.Fprintf(&b, "  %d finding(s)\n\n", len(m.findings))
+
+	end := m.offset + m.listHeight()
+	if end > len(m.findings) {
+		end = len(m.findings)
+	}
+	for i := m.offset; i < end; i++ {
+		f := m.findings[i]
+		label, ok := decisionLabels[m.decisions[f.Fingerprint(m.root)]]
+		if !ok {
+			label = red + "[  ]" + reset
+		}
+		row := fmt.Sprintf("%s:%d  %s", m.relPath(f.Path), f.Line, truncate(f.Text, 60))
+		if i == m.cursor {
+			row = invert + row + reset
+		}
+		b.WriteString(label + " " + row + "\n")
+	}
+
+	f := m.current()
+	b.WriteString("\n" + yellow + "---------------------------------------" + reset + "\n")
+	fmt.Fprintf(&b, "%sFile:%s %s  %sLine %d (Col %d)%s\n", green, reset, m.relPath(f.Path), red, f.Line, f.Column, reset)
+
+	first, lines, err := core.ReadContext(f.Path, f.Line, contextRadius)
+	if err != nil {
+		b.WriteString(faint + "  (source unavailable: " + err.Error() + ")" + reset + "\n")
+	}
+	for i, l := range lines {
+		n := first + i
+		if n == f.Line {
+			fmt.Fprintf(&b, "%s> %4d | %s%s\n", red, n, truncate(l, 120), reset)
+		} else {
+			fmt.Fprintf(&b, "%s  %4d | %s%s\n", faint, n, truncate(l, 120), reset)
+		}
+	}
+	for k, v := range f.Payload {
+		fmt.Fprintf(&b, "  %s%s:%s %v\n", red, k, reset, v)
+	}
+
+	b.WriteString("\n")
+	if m.status != "" {
+		b.WriteString(m.status + "\n")
+	}
+	b.WriteString(faint + "j/k move • f false positive (baseline) • a inline allow • i ignore file • o obfuscate file • u undo • q quit" + reset + "\n")
+	return b.String()
+}